@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+// abbreviate returns the last n elements of the module path p, not
+// counting any major version suffix, which is kept.
+func abbreviate(p string, n int) string {
+	prefix, major, ok := module.SplitPathVersion(p)
+	if !ok {
+		prefix, major = p, ""
+	}
+	elems := strings.Split(prefix, "/")
+	return strings.Join(elems[max(len(elems)-n, 0):], "/") + major
+}
+
+// abbreviations returns a short name for each node in g: the last
+// element of its path, lengthened by preceding elements as many times
+// as needed to make it differ from the names of all the other nodes.
+func (g *graph) abbreviations() map[string]string {
+	lengths := make(map[string]int)
+	for n := range g.nodes {
+		lengths[n] = 1
+	}
+	for {
+		byName := make(map[string][]string)
+		for n, length := range lengths {
+			name := abbreviate(n, length)
+			byName[name] = append(byName[name], n)
+		}
+		changed := false
+		for _, ns := range byName {
+			if len(ns) < 2 {
+				continue
+			}
+			for _, n := range ns {
+				if abbreviate(n, lengths[n]+1) != abbreviate(n, lengths[n]) {
+					lengths[n]++
+					changed = true
+				}
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	names := make(map[string]string)
+	for n, length := range lengths {
+		names[n] = abbreviate(n, length)
+	}
+	return names
+}
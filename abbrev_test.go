@@ -0,0 +1,38 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAbbreviations(t *testing.T) {
+	g := edgeGraph(
+		[2]string{"example.com/main", "example.com/a"},
+		[2]string{"example.com/main", "other.org/a"},
+		[2]string{"example.com/main", "github.com/foo/bar/v2"},
+		[2]string{"example.com/main", "github.com/baz/bar"},
+		// b/c cannot be lengthened, so only x/b/c is.
+		[2]string{"example.com/main", "x/b/c"},
+		[2]string{"example.com/main", "b/c"},
+	)
+	want := map[string]string{
+		"example.com/main":      "main",
+		"example.com/a":         "example.com/a",
+		"other.org/a":           "other.org/a",
+		"github.com/foo/bar/v2": "bar/v2",
+		"github.com/baz/bar":    "bar",
+		"x/b/c":                 "x/b/c",
+		"b/c":                   "b/c",
+	}
+	if got := g.abbreviations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got abbreviations %v, want %v", got, want)
+	}
+}
+
+func TestAbbrev(t *testing.T) {
+	got := mermaidNodes(mustRun(t, fixture(t, "fx/main"), "-abbrev"))
+	want := []string{"a", "b", "c", "d", "e", "f", "g", "main", "t", "x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got node labels %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// aliasGroup maps the modules matching a pattern
+// to a single virtual module.
+type aliasGroup struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// aliasGroups implements flag.Value for the repeatable -alias-group flag.
+// A module belongs to the first group whose pattern it matches.
+type aliasGroups []aliasGroup
+
+func (a *aliasGroups) String() string {
+	return strings.Join(a.values(), " ")
+}
+
+func (a *aliasGroups) Set(s string) error {
+	name, expr, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("alias group %q is not of the form NAME=REGEXP", s)
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+	*a = append(*a, aliasGroup{name, pattern})
+	return nil
+}
+
+func (a *aliasGroups) values() []string {
+	var vs []string
+	for _, group := range *a {
+		vs = append(vs, group.name+"="+group.pattern.String())
+	}
+	return vs
+}
+
+// alias returns the name of the group that module belongs to,
+// or module itself if it belongs to none.
+func (a aliasGroups) alias(module string) string {
+	for _, group := range a {
+		if group.pattern.MatchString(module) {
+			return group.name
+		}
+	}
+	return module
+}
+
+// mergeAliases replaces the modules in g that belong to one of
+// the given groups by a single node for the group. Edges between
+// members of the same group are dropped and other edges are
+// redirected to or from the group's node. A group is test-only
+// only if all its members are.
+func (g *graph) mergeAliases(groups aliasGroups) {
+	rename := func(m map[string]map[string]struct{}) map[string]map[string]struct{} {
+		res := make(map[string]map[string]struct{})
+		for from, tos := range m {
+			from := groups.alias(from)
+			for to := range tos {
+				to := groups.alias(to)
+				if from == to {
+					continue
+				}
+				if res[from] == nil {
+					res[from] = make(map[string]struct{})
+				}
+				res[from][to] = struct{}{}
+			}
+		}
+		return res
+	}
+	counts := make(map[edge]int)
+	for e, n := range g.counts {
+		if e := (edge{groups.alias(e.from), groups.alias(e.to)}); e.from != e.to {
+			counts[e] += n
+		}
+	}
+	nodes := make(map[string]struct{})
+	members := make(map[string]int)
+	testMembers := make(map[string]int)
+	for n := range g.nodes {
+		alias := groups.alias(n)
+		nodes[alias] = struct{}{}
+		if alias == n {
+			continue
+		}
+		members[alias]++
+		if _, ok := g.testOnly[n]; ok {
+			testMembers[alias]++
+		}
+		delete(g.testOnly, n)
+	}
+	for alias, n := range members {
+		if testMembers[alias] == n {
+			g.testOnly[alias] = struct{}{}
+		}
+		g.notes[alias] = append(g.notes[alias], fmt.Sprintf("(%d modules)", n))
+	}
+	g.mainMod = groups.alias(g.mainMod)
+	g.nodes = nodes
+	g.edges = rename(g.edges)
+	g.prodEdges = rename(g.prodEdges)
+	g.counts = counts
+}
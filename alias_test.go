@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAliasGroup(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	out := mustRun(t, dir, "-format", "porcelain",
+		"-alias-group", `cd=^example\.com/[cd]$`,
+		"-alias-group", `fgx=^example\.com/[fgx]$`,
+	)
+	want := []string{
+		"N\tcd\tregularDep\t",
+		"N\texample.com/a\tregularDep\tv1.0.0",
+		"N\texample.com/b\tregularDep\tv1.0.0",
+		"N\texample.com/e\tregularDep\tv1.0.0",
+		"N\texample.com/main\tmainModule\t",
+		"N\texample.com/t\tregularDep\tv1.0.0",
+		// All the members of fgx are test-only, so it is too.
+		"N\tfgx\ttestOnlyDep\t",
+		// The edge from example.com/f to example.com/g is
+		// within fgx, so it is dropped.
+		"E\tcd\tfgx",
+		"E\texample.com/a\tcd",
+		"E\texample.com/a\tfgx",
+		"E\texample.com/b\tcd",
+		"E\texample.com/main\texample.com/a",
+		"E\texample.com/main\texample.com/b",
+		"E\texample.com/main\texample.com/t",
+		"E\texample.com/t\texample.com/e",
+	}
+	if got := porcelainLines(out); !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	out = mustRun(t, dir, "-alias-group", `cx=^example\.com/[cx]$`)
+	if !strings.Contains(out, `N0["cx (2 modules)"]`) {
+		t.Errorf("output does not show the group with its size:\n%s", out)
+	}
+	// Not all the members of cx are test-only.
+	if !strings.Contains(out, "class N0,N1,N2,N3,N4,N8 regularDep;\n") {
+		t.Errorf("group with a production member is test-only:\n%s", out)
+	}
+}
+
+func TestAliasGroupsSet(t *testing.T) {
+	var groups aliasGroups
+	for _, bad := range []string{"x", "=x", "x=("} {
+		if err := groups.Set(bad); err == nil {
+			t.Errorf("Set(%q) succeeded, want error", bad)
+		}
+	}
+	for _, s := range []string{"first=^a", "second=a=b"} {
+		if err := groups.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for module, want := range map[string]string{"abc": "first", "xa=b": "second", "other": "other"} {
+		if got := groups.alias(module); got != want {
+			t.Errorf("alias(%q) = %q, want %q", module, got, want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// newDepStyle is the mermaid style used to highlight modules
+// that are not present in the -baseline module list.
+const newDepStyle = "stroke:#c0c,stroke-width:3px,font-weight:bold"
+
+// readModuleList reads a list of modules from the named file. Each
+// non-blank line that does not start with # names a module as its first
+// field, so the output of "go list -m all" is suitable as well as
+// a plain list of module paths.
+func readModuleList(file string) (map[string]struct{}, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	mods := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		mods[fields[0]] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mods, nil
+}
+
+// newEdgeStyle is the link style used to highlight edges
+// that are not present in the -baseline-edges edge list.
+const newEdgeStyle = "stroke:#c0c,stroke-width:3px"
+
+// readEdgeList reads a list of edges from the named file. Each
+// non-blank line that does not start with # holds the source and
+// target modules of an edge as its first two fields; a leading E
+// field, as in the porcelain format, and any @version suffixes,
+// as in the output of "go mod graph", are ignored.
+func readEdgeList(file string) (map[edge]bool, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	edges := make(map[edge]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 && fields[0] == "E" {
+			fields = fields[1:]
+		}
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		from, _, _ := strings.Cut(fields[0], "@")
+		to, _, _ := strings.Cut(fields[1], "@")
+		edges[edge{from, to}] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return edges, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"container/list"
+	"log"
+	"strings"
+)
+
+// indirectBlame returns, for each module in g that is neither the main
+// module nor one of the given direct requirements, the direct
+// requirements through which it enters the graph: those from which it
+// can be reached without passing through another direct requirement.
+func (g *graph) indirectBlame(direct map[string]struct{}) map[string][]string {
+	blame := make(map[string][]string)
+	for _, d := range sortedKeys(intersection(direct, g.nodes)) {
+		seen := map[string]bool{d: true}
+		q := list.New()
+		q.PushBack(d)
+		for q.Len() > 0 {
+			n := q.Remove(q.Front()).(string)
+			for _, to := range sortedKeys(g.edges[n]) {
+				if _, isDirect := direct[to]; isDirect || seen[to] || to == g.mainMod {
+					continue
+				}
+				seen[to] = true
+				blame[to] = append(blame[to], d)
+				q.PushBack(to)
+			}
+		}
+	}
+	return blame
+}
+
+// showIndirectBlame logs the direct requirements responsible for each
+// indirect module in g, and adds them to the indirect module's tooltip.
+func showIndirectBlame(g *graph) {
+	direct, err := directRequirements()
+	if err != nil {
+		log.Fatalf("cannot read requirements: %v", err)
+	}
+	blame := g.indirectBlame(direct)
+	for _, n := range sortedKeys(blame) {
+		via := strings.Join(blame[n], ", ")
+		log.Printf("indirect: %s via %s", n, via)
+		g.addTooltip(n, "Required via "+via)
+	}
+}
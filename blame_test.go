@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIndirectBlame(t *testing.T) {
+	g := edgeGraph(
+		[2]string{"main", "a"},
+		[2]string{"main", "b"},
+		[2]string{"a", "c"},
+		[2]string{"b", "c"},
+		// An edge to a direct requirement does not bring it in
+		// indirectly, nor anything below it.
+		[2]string{"a", "b"},
+		[2]string{"b", "d"},
+		[2]string{"d", "main"},
+	)
+	g.mainMod = "main"
+	got := g.indirectBlame(map[string]struct{}{"a": {}, "b": {}, "unused": {}})
+	want := map[string][]string{
+		"c": {"a", "b"},
+		"d": {"b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got blame %v, want %v", got, want)
+	}
+}
+
+func TestIndirectBlameFlag(t *testing.T) {
+	r := runMain(t, fixture(t, "fx/main"), nil, "-indirect-blame")
+	if r.failed {
+		t.Fatalf("-indirect-blame failed:\n%s", r.stderr)
+	}
+	for _, want := range []string{
+		"indirect: example.com/c via example.com/a, example.com/b\n",
+		"indirect: example.com/e via example.com/t\n",
+		"indirect: example.com/g via example.com/a\n",
+	} {
+		if !strings.Contains(r.stderr, want) {
+			t.Errorf("log does not contain %q:\n%s", want, r.stderr)
+		}
+	}
+	if !strings.Contains(r.stdout, "Required via example.com/a, example.com/b") {
+		t.Errorf("output has no tooltip for example.com/c:\n%s", r.stdout)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// branchDepthRule limits the depth of the subtree below
+// each module matching a pattern.
+type branchDepthRule struct {
+	pattern *regexp.Regexp
+	depth   int
+}
+
+// branchDepthRules implements flag.Value for the repeatable
+// -max-depth-per-branch flag. A module takes the limit of the
+// first rule whose pattern it matches.
+type branchDepthRules []branchDepthRule
+
+func (r *branchDepthRules) String() string {
+	return strings.Join(r.values(), " ")
+}
+
+func (r *branchDepthRules) values() []string {
+	var rules []string
+	for _, rule := range *r {
+		rules = append(rules, fmt.Sprintf("%s=%d", rule.pattern, rule.depth))
+	}
+	return rules
+}
+
+func (r *branchDepthRules) Set(s string) error {
+	i := strings.LastIndex(s, "=")
+	if i < 0 {
+		return fmt.Errorf("depth rule %q is not of the form REGEXP=DEPTH", s)
+	}
+	pattern, err := regexp.Compile(s[:i])
+	if err != nil {
+		return err
+	}
+	depth, err := strconv.Atoi(s[i+1:])
+	if err != nil || depth < 0 {
+		return fmt.Errorf("invalid depth in rule %q", s)
+	}
+	*r = append(*r, branchDepthRule{pattern, depth})
+	return nil
+}
+
+// limit returns the depth limit for the subtree below
+// the given module, or -1 if there is none.
+func (r branchDepthRules) limit(module string) int {
+	for _, rule := range r {
+		if rule.pattern.MatchString(module) {
+			return rule.depth
+		}
+	}
+	return -1
+}
+
+// pruneBranches removes from g each module that can be reached from the
+// main module only by going deeper below some module than the rules allow.
+// A limit of 0 keeps the matching module but nothing below it. Modules
+// that are not reachable from the main module in the first place are
+// left alone.
+func (g *graph) pruneBranches(rules branchDepthRules) {
+	// budget holds the remaining depth allowed below each node
+	// on the least restricted path found to it so far, with -1
+	// meaning that it is unlimited.
+	budget := make(map[string]int)
+	better := func(b, than int) bool {
+		return than >= 0 && (b < 0 || b > than)
+	}
+	q := list.New()
+	visit := func(n string, b int) {
+		if l := rules.limit(n); l >= 0 && (b < 0 || l < b) {
+			b = l
+		}
+		if old, ok := budget[n]; ok && !better(b, old) {
+			return
+		}
+		budget[n] = b
+		q.PushBack(n)
+	}
+	visit(g.mainMod, -1)
+	for q.Len() > 0 {
+		n := q.Remove(q.Front()).(string)
+		b := budget[n]
+		if b == 0 {
+			continue
+		}
+		for to := range g.edges[n] {
+			if b < 0 {
+				visit(to, -1)
+			} else {
+				visit(to, b-1)
+			}
+		}
+	}
+	keep := difference(g.nodes, g.reachable(g.mainMod))
+	for n := range budget {
+		keep[n] = struct{}{}
+	}
+	g.keepNodes(keep)
+}
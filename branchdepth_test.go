@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPruneBranches(t *testing.T) {
+	g := edgeGraph(
+		[2]string{"main", "big"},
+		[2]string{"big", "p"},
+		[2]string{"p", "q"},
+		[2]string{"q", "r"},
+		// q is also reachable without passing through big.
+		[2]string{"main", "y"},
+		[2]string{"y", "q"},
+		[2]string{"main", "leaf"},
+		[2]string{"leaf", "z"},
+		// Modules that the main module cannot reach are left alone.
+		[2]string{"island", "z2"},
+	)
+	g.mainMod = "main"
+	var rules branchDepthRules
+	for _, s := range []string{"^big$=1", "^leaf$=0"} {
+		if err := rules.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.pruneBranches(rules)
+	want := []string{"big", "island", "leaf", "main", "p", "q", "r", "y", "z2"}
+	if got := sortedKeys(g.nodes); !reflect.DeepEqual(got, want) {
+		t.Errorf("got nodes %q, want %q", got, want)
+	}
+	if _, ok := g.edges["leaf"]["z"]; ok {
+		t.Errorf("edge to pruned module z remains")
+	}
+}
+
+func TestBranchDepthRulesSet(t *testing.T) {
+	var rules branchDepthRules
+	for _, s := range []string{"a=b=2", "x"} {
+		rules.Set(s)
+	}
+	if got, want := rules.values(), []string{"a=b=2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got rules %q, want %q", got, want)
+	}
+	for _, bad := range []string{"x", "(=1", "x=-1", "x=y"} {
+		if err := rules.Set(bad); err == nil {
+			t.Errorf("Set(%q) succeeded, want error", bad)
+		}
+	}
+	if got := rules.limit("xa=b"); got != 2 {
+		t.Errorf("limit(xa=b) = %d, want 2", got)
+	}
+	if got := rules.limit("other"); got != -1 {
+		t.Errorf("limit(other) = %d, want -1", got)
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// cacheVersion identifies the format of cache entries.
+// It must be incremented whenever cacheEntry changes.
+const cacheVersion = 13
+
+// cacheEntry is the on-disk form of a cached graph.
+type cacheEntry struct {
+	// Inputs holds the modification time of each file and
+	// directory that the graph was derived from in the main module
+	// and in any modules replaced by local directories.
+	Inputs  map[string]time.Time `json:"inputs"`
+	MainMod string               `json:"mainMod"`
+	Nodes   []string             `json:"nodes"`
+	Edges   []cacheEdge          `json:"edges"`
+	// ProdEdges holds the edges found without loading tests.
+	ProdEdges []cacheEdge       `json:"prodEdges"`
+	TestOnly  []string          `json:"testOnly"`
+	Versions  map[string]string `json:"versions"`
+	Packages  map[string]int    `json:"packages"`
+	Replaces  map[string]string `json:"replaces"`
+	// LocalReplaces holds the modules replaced by a local directory.
+	LocalReplaces []string `json:"localReplaces"`
+	// TestOnlyPackages holds the packages found only with tests.
+	TestOnlyPackages []string `json:"testOnlyPackages"`
+	// Conflicts holds modules resolved at more than one version.
+	Conflicts map[string]map[string][]string `json:"conflicts"`
+	// Deprecated holds the deprecation message of each deprecated module.
+	Deprecated map[string]string `json:"deprecated"`
+	Dirs       map[string]string `json:"dirs"`
+	// Retracted holds the retraction rationale of each
+	// module whose selected version is retracted.
+	Retracted map[string]string `json:"retracted"`
+	MainUsage map[string]int    `json:"mainUsage"`
+	// Broken holds the modules none of whose packages loaded.
+	Broken []string `json:"broken"`
+}
+
+type cacheEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Count int    `json:"count"`
+}
+
+// readCache returns the graph cached in dir for the given patterns,
+// or nil if there is no valid cache entry.
+func readCache(dir string, patterns []string) *graph {
+	file, err := cacheFile(dir, patterns)
+	if err != nil {
+		log.Printf("cannot use cache: %v", err)
+		return nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("cannot read cache: %v", err)
+		}
+		return nil
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		log.Printf("ignoring invalid cache file %s: %v", file, err)
+		return nil
+	}
+	for path, mtime := range e.Inputs {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(mtime) {
+			tracef("cache: %s has changed", path)
+			return nil
+		}
+	}
+	tracef("cache: using %s", file)
+	g := &graph{
+		mainMod:    e.MainMod,
+		nodes:      make(map[string]struct{}),
+		edges:      make(map[string]map[string]struct{}),
+		testOnly:   make(map[string]struct{}),
+		versions:   e.Versions,
+		pkgCounts:  e.Packages,
+		replaces:   e.Replaces,
+		conflicts:  e.Conflicts,
+		deprecated: e.Deprecated,
+		dirs:       e.Dirs,
+		retracted:  e.Retracted,
+		mainUsage:  e.MainUsage,
+		tooltips:   make(map[string]string),
+		prodEdges:  make(map[string]map[string]struct{}),
+		counts:     make(map[edge]int),
+		edgeStyles: make(map[edge]string),
+		edgeLabels: make(map[edge]string),
+		notes:      make(map[string][]string),
+
+		localReplaces: make(map[string]struct{}),
+		testOnlyPkgs:  make(map[string]struct{}),
+		broken:        make(map[string]struct{}),
+	}
+	for _, n := range e.Broken {
+		g.broken[n] = struct{}{}
+	}
+	for _, n := range e.Nodes {
+		g.nodes[n] = struct{}{}
+	}
+	for _, n := range e.TestOnly {
+		g.testOnly[n] = struct{}{}
+	}
+	for _, n := range e.LocalReplaces {
+		g.localReplaces[n] = struct{}{}
+	}
+	for _, n := range e.TestOnlyPackages {
+		g.testOnlyPkgs[n] = struct{}{}
+	}
+	for _, ce := range e.Edges {
+		if g.edges[ce.From] == nil {
+			g.edges[ce.From] = make(map[string]struct{})
+		}
+		g.edges[ce.From][ce.To] = struct{}{}
+		g.counts[edge{ce.From, ce.To}] = ce.Count
+	}
+	for _, ce := range e.ProdEdges {
+		if g.prodEdges[ce.From] == nil {
+			g.prodEdges[ce.From] = make(map[string]struct{})
+		}
+		g.prodEdges[ce.From][ce.To] = struct{}{}
+	}
+	return g
+}
+
+// writeCache stores g in dir for the given patterns. The files found in pkgs
+// that belong to the main module or to a module replaced by a local
+// directory are recorded, along with the go.mod files of those modules,
+// so that any change to them invalidates the entry.
+// Failures are logged but otherwise ignored, as the cache is only an optimization.
+func writeCache(dir string, patterns []string, g *graph, pkgs []*packages.Package) {
+	file, err := cacheFile(dir, patterns)
+	if err != nil {
+		log.Printf("cannot use cache: %v", err)
+		return
+	}
+	e := cacheEntry{
+		Inputs:     make(map[string]time.Time),
+		MainMod:    g.mainMod,
+		Nodes:      sortedKeys(g.nodes),
+		TestOnly:   sortedKeys(g.testOnly),
+		Versions:   g.versions,
+		Packages:   g.pkgCounts,
+		Replaces:   g.replaces,
+		Conflicts:  g.conflicts,
+		Deprecated: g.deprecated,
+		Dirs:       g.dirs,
+		Retracted:  g.retracted,
+		MainUsage:  g.mainUsage,
+		Edges:      []cacheEdge{},
+		ProdEdges:  []cacheEdge{},
+
+		LocalReplaces:    sortedKeys(g.localReplaces),
+		TestOnlyPackages: sortedKeys(g.testOnlyPkgs),
+		Broken:           sortedKeys(g.broken),
+	}
+	addInput := func(path string) {
+		if info, err := os.Stat(path); err == nil {
+			e.Inputs[path] = info.ModTime()
+		}
+	}
+	traverse(pkgs, func(p *packages.Package) {
+		m := p.Module
+		if m == nil {
+			return
+		}
+		if !m.Main {
+			// Only a local replacement can change
+			// without its version changing.
+			if m.Replace == nil || m.Replace.Version != "" {
+				return
+			}
+			m = m.Replace
+		}
+		addInput(m.GoMod)
+		for _, f := range p.GoFiles {
+			addInput(f)
+			addInput(filepath.Dir(f))
+		}
+	})
+	for _, from := range sortedKeys(g.nodes) {
+		for _, to := range sortedKeys(g.edges[from]) {
+			e.Edges = append(e.Edges, cacheEdge{
+				From:  from,
+				To:    to,
+				Count: g.counts[edge{from, to}],
+			})
+		}
+	}
+	for _, from := range sortedKeys(g.prodEdges) {
+		for _, to := range sortedKeys(g.prodEdges[from]) {
+			e.ProdEdges = append(e.ProdEdges, cacheEdge{From: from, To: to})
+		}
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("cannot encode cache entry: %v", err)
+		return
+	}
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		log.Printf("cannot create cache directory: %v", err)
+		return
+	}
+	// Write to a temporary file first so that concurrent
+	// runs never see a partially written entry.
+	f, err := os.CreateTemp(dir, "tmp-*")
+	if err != nil {
+		log.Printf("cannot write cache: %v", err)
+		return
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), file)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		log.Printf("cannot write cache: %v", err)
+	}
+}
+
+// cacheFile returns the name of the cache file in dir for the given patterns.
+// It is keyed by a hash of the current module's go.mod and go.sum files and
+// of any go.work and go.work.sum files in use, as well as the patterns
+// themselves and any options that affect loading.
+func cacheFile(dir string, patterns []string) (string, error) {
+	goMod, err := goModFile()
+	if err != nil {
+		return "", err
+	}
+	files := []string{goMod, filepath.Join(filepath.Dir(goMod), "go.sum")}
+	goWork, err := goWorkFile()
+	if err != nil {
+		return "", err
+	}
+	if goWork != "" {
+		files = append(files, goWork, goWork+".sum")
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "gotestdeps cache %d\n", cacheVersion)
+	for _, name := range files {
+		data, err := os.ReadFile(name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		fmt.Fprintf(h, "file %q %d\n", name, len(data))
+		h.Write(data)
+	}
+	for _, p := range patterns {
+		fmt.Fprintf(h, "pattern %q\n", p)
+	}
+	for _, opt := range loadOptions() {
+		fmt.Fprintf(h, "option %q\n", opt)
+	}
+	return filepath.Join(dir, fmt.Sprintf("%x.json", h.Sum(nil))), nil
+}
+
+// goModFile returns the path of the current module's go.mod file.
+func goModFile() (string, error) {
+	out, err := exec.Command("go", "env", "GOMOD").Output()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine go.mod location: %v", err)
+	}
+	goMod := strings.TrimSpace(string(out))
+	if goMod == "" || goMod == os.DevNull {
+		return "", fmt.Errorf("not inside a module")
+	}
+	return goMod, nil
+}
+
+// goWorkFile returns the path of the go.work file in use,
+// or the empty string if there is none.
+func goWorkFile() (string, error) {
+	out, err := exec.Command("go", "env", "GOWORK").Output()
+	if err != nil {
+		return "", fmt.Errorf("cannot determine go.work location: %v", err)
+	}
+	goWork := strings.TrimSpace(string(out))
+	if goWork == "off" {
+		return "", nil
+	}
+	return goWork, nil
+}
+
+// sortedKeys returns the elements of the set m in sorted order.
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheSeesLocalReplacements(t *testing.T) {
+	root := t.TempDir()
+	copyDir(t, root, fixture(t, "fx"))
+	dir, cache := filepath.Join(root, "main"), filepath.Join(root, "cache")
+	before := mustRun(t, dir, "-cache", cache, "-format", "porcelain")
+	if strings.Contains(before, "E\texample.com/a\texample.com/d\n") {
+		t.Fatalf("example.com/a already depends on example.com/d:\n%s", before)
+	}
+	aFile := filepath.Join(root, "a", "a.go")
+	writeFile(t, aFile, "package a\n\nimport (\n\t\"example.com/c\"\n\t\"example.com/d\"\n)\n\nfunc A() {\n\tc.C()\n\td.D()\n}\n")
+	// Make sure that the change is visible even where
+	// modification times are coarse.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(aFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+	after := mustRun(t, dir, "-cache", cache, "-format", "porcelain")
+	if !strings.Contains(after, "E\texample.com/a\texample.com/d\n") {
+		t.Errorf("cached graph misses the change to the local replacement of example.com/a:\n%s", after)
+	}
+}
+
+func TestCacheKeepsBroken(t *testing.T) {
+	dir := t.TempDir()
+	g := edgeGraph([2]string{"example.com/main", "example.com/broken"})
+	g.mainMod = "example.com/main"
+	g.broken = map[string]struct{}{"example.com/broken": {}}
+	patterns := []string{"all"}
+	writeCache(dir, patterns, g, nil)
+	cached := readCache(dir, patterns)
+	if cached == nil {
+		t.Fatal("no cache entry written")
+	}
+	if !reflect.DeepEqual(cached.broken, g.broken) {
+		t.Errorf("got broken modules %v from the cache, want %v", cached.broken, g.broken)
+	}
+}
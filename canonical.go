@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+// canonicalGraph holds the nodes and edges of a graph in the order
+// in which every machine-readable format writes them: nodes sorted by
+// path and edges sorted by source and then target. Formats should
+// take their output from a canonicalGraph rather than from the maps
+// of a graph so that they cannot disagree about the order.
+type canonicalGraph struct {
+	main  string
+	nodes []canonicalNode
+	edges []canonicalEdge
+}
+
+type canonicalNode struct {
+	path string
+	// class holds the built-in class of the node, as
+	// returned by baseClass.
+	class   string
+	version string
+	// testOnly is never set for the main module.
+	testOnly bool
+}
+
+type canonicalEdge struct {
+	from, to string
+	// test reports whether the edge is found only when tests are loaded.
+	test bool
+	// count holds the number of package imports that
+	// the edge stands for, or 0 if that is unknown.
+	count int
+}
+
+// canonicalize returns the nodes and edges of g in canonical order.
+func canonicalize(g *graph) canonicalGraph {
+	cg := canonicalGraph{main: g.mainMod}
+	nodes := sortedKeys(g.nodes)
+	for _, n := range nodes {
+		_, testOnly := g.testOnly[n]
+		cg.nodes = append(cg.nodes, canonicalNode{
+			path:     n,
+			class:    g.baseClass(n),
+			version:  g.versions[n],
+			testOnly: testOnly && n != g.mainMod,
+		})
+	}
+	for _, from := range nodes {
+		for _, to := range sortedKeys(g.edges[from]) {
+			_, prod := g.prodEdges[from][to]
+			cg.edges = append(cg.edges, canonicalEdge{
+				from:  from,
+				to:    to,
+				test:  !prod,
+				count: g.counts[edge{from, to}],
+			})
+		}
+	}
+	return cg
+}
+
+// writeCanonical writes g in a normalized, sorted text form without
+// versions, followed by a line holding the SHA-256 digest of everything
+// before it. Two graphs with the same modules, test-only status and
+// edges always produce identical output, so comparing the digests
+// is enough to tell whether the dependency graph has changed.
+func writeCanonical(out io.Writer, g *graph) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# canonical 1\n")
+	cg := canonicalize(g)
+	for _, n := range cg.nodes {
+		kind := "prod"
+		if n.path == cg.main {
+			kind = "main"
+		} else if n.testOnly {
+			kind = "test"
+		}
+		fmt.Fprintf(&buf, "node %s %s\n", n.path, kind)
+	}
+	for _, e := range cg.edges {
+		fmt.Fprintf(&buf, "edge %s %s\n", e.from, e.to)
+	}
+	out.Write(buf.Bytes())
+	fmt.Fprintf(out, "# digest: %x\n", sha256.Sum256(buf.Bytes()))
+}
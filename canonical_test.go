@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// machineFormats holds the formats whose output is taken from
+// canonicalize.
+var machineFormats = []string{"json", "porcelain", "canonical", "cypher", "ndjson-events", "go", "make"}
+
+// eventTime matches the timestamp of an ndjson-events line,
+// which is the only part of the output that may change between runs.
+var eventTime = regexp.MustCompile(`"ts":"[^"]*"`)
+
+func TestMachineFormatsDeterministic(t *testing.T) {
+	g, _ := loadGraph(fixture(t, "fx/main"), []string{"all"})
+	for _, format := range machineFormats {
+		var first string
+		for i := 0; i < 10; i++ {
+			var buf bytes.Buffer
+			formats[format].write(&buf, g)
+			got := eventTime.ReplaceAllString(buf.String(), `"ts":""`)
+			if i == 0 {
+				first = got
+			} else if got != first {
+				t.Fatalf("-format %s differs between runs; first:\n%s\nlater:\n%s", format, first, got)
+			}
+		}
+	}
+}
+
+func TestMachineFormatsIgnoreColors(t *testing.T) {
+	colors := filepath.Join(t.TempDir(), "colors")
+	writeFile(t, colors, "^example\\.com/[af]$=#123456\n")
+	dir := fixture(t, "fx/main")
+	for _, format := range []string{"porcelain", "json"} {
+		want := mustRun(t, dir, "-format", format)
+		if got := mustRun(t, dir, "-format", format, "-colors-file", colors); got != want {
+			t.Errorf("-colors-file changes -format %s; without it:\n%s\nwith it:\n%s", format, want, got)
+		}
+	}
+	out := mustRun(t, dir, "-format", "porcelain", "-colors-file", colors)
+	for _, line := range []string{"N\texample.com/a\tregularDep\t", "N\texample.com/f\ttestOnlyDep\t"} {
+		if !strings.Contains(out, line) {
+			t.Errorf("porcelain output has no line starting %q:\n%s", line, out)
+		}
+	}
+}
+
+func TestCanonicalGolden(t *testing.T) {
+	out := mustRun(t, fixture(t, "fx/main"), "-format", "canonical")
+	checkGolden(t, "fx.canonical", out)
+	body, digest, ok := strings.Cut(out, "# digest: ")
+	if !ok {
+		t.Fatalf("no digest line in output:\n%s", out)
+	}
+	if want := fmt.Sprintf("%x\n", sha256.Sum256([]byte(body))); digest != want {
+		t.Errorf("got digest %q, want %q", digest, want)
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// writeMermaidClass writes g as a mermaid class diagram inside a
+// markdown code block, with a class for each module annotated with
+// its classification and a dependency arrow for each edge. Module
+// paths are not valid class names, so each class is given an
+// identifier of the form N%d and labeled with the path.
+func writeMermaidClass(out io.Writer, g *graph) {
+	indent := "    "
+	if *flagMinify {
+		indent = ""
+	}
+	fmt.Fprintf(out, "```mermaid\n")
+	fmt.Fprintf(out, "classDiagram\n")
+	nodes := sortedKeys(g.nodes)
+	indexes := make(map[string]int)
+	for i, n := range nodes {
+		indexes[n] = i
+		fmt.Fprintf(out, "%sclass N%d[%s]\n", indent, i, mermaidQuote(g.label(n)))
+		fmt.Fprintf(out, "%s<<%s>> N%d\n", indent, g.class(n), i)
+	}
+	for _, from := range nodes {
+		for _, to := range g.successors(from) {
+			fmt.Fprintf(out, "%sN%d ..> N%d\n", indent, indexes[from], indexes[to])
+		}
+	}
+	fmt.Fprintf(out, "```\n")
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// moduleInfo describes a module for the purposes of classification.
+type moduleInfo struct {
+	Path     string
+	Version  string
+	Main     bool
+	TestOnly bool
+}
+
+// classifier decides the class of a module and the fill color used
+// to draw it. It returns false if it has no opinion about the module.
+type classifier func(mod moduleInfo) (class, color string, ok bool)
+
+// builtinClassifiers holds the default classifiers, which between
+// them classify every module.
+var builtinClassifiers = []classifier{
+	func(mod moduleInfo) (string, string, bool) {
+		return "mainModule", *flagMainColor, mod.Main
+	},
+	func(mod moduleInfo) (string, string, bool) {
+		return "testOnlyDep", *flagTestColor, mod.TestOnly
+	},
+	func(mod moduleInfo) (string, string, bool) {
+		return "regularDep", *flagRegularColor, true
+	},
+}
+
+// customClassifiers holds the classifiers added by registerClassifier.
+var customClassifiers []classifier
+
+// registerClassifier adds a classifier that takes priority over the
+// built-in ones. Classifiers are consulted in the order in which they
+// were registered and the first one to recognize a module decides its
+// class. This allows organization-specific rules, such as distinguishing
+// internal from external modules, to be added in one place. It is an
+// extension point within this command only, used by -colors-file and
+// -codeowners; as gotestdeps is a main package, other programs cannot
+// register classifiers.
+func registerClassifier(c classifier) {
+	customClassifiers = append(customClassifiers, c)
+}
+
+// moduleInfo returns the description of the given node
+// that is passed to classifiers.
+func (g *graph) moduleInfo(name string) moduleInfo {
+	_, testOnly := g.testOnly[name]
+	return moduleInfo{
+		Path:     name,
+		Version:  g.versions[name],
+		Main:     name == g.mainMod,
+		TestOnly: testOnly,
+	}
+}
+
+// classify returns the class and color of the given node
+// along with the priority of the classifier that chose them.
+func (g *graph) classify(name string) (class, color string, priority int) {
+	mod := g.moduleInfo(name)
+	for i, c := range customClassifiers {
+		if class, color, ok := c(mod); ok {
+			return class, color, i
+		}
+	}
+	for i, c := range builtinClassifiers {
+		if class, color, ok := c(mod); ok {
+			return class, color, len(customClassifiers) + i
+		}
+	}
+	panic("unreachable: no classifier recognized " + name)
+}
+
+// class returns the name of the class that the given node belongs to.
+func (g *graph) class(name string) string {
+	class, _, _ := g.classify(name)
+	return class
+}
+
+// baseClass returns the built-in class of the given node, mainModule,
+// testOnlyDep or regularDep, which is what the machine-readable formats
+// record. Unlike class, it ignores the custom classifiers, such as
+// those of -colors-file and -codeowners, which only decide how nodes
+// are drawn.
+func (g *graph) baseClass(name string) string {
+	mod := g.moduleInfo(name)
+	for _, c := range builtinClassifiers {
+		if class, _, ok := c(mod); ok {
+			return class
+		}
+	}
+	panic("unreachable: no classifier recognized " + name)
+}
+
+// nodeClass describes a class used by some nodes in the graph.
+type nodeClass struct {
+	name  string
+	color string
+}
+
+// classMembers returns the members of each class used by the given
+// nodes, in the order given. Each node is classified just once, as
+// classifying is costly when there are many custom classifiers.
+func (g *graph) classMembers(nodes []string) map[nodeClass][]string {
+	members := make(map[nodeClass][]string)
+	for _, name := range nodes {
+		class, color, _ := g.classify(name)
+		c := nodeClass{class, color}
+		members[c] = append(members[c], name)
+	}
+	return members
+}
+
+// classes returns all the classes used by nodes in g in
+// priority order, then by name.
+func (g *graph) classes() []nodeClass {
+	priorities := make(map[nodeClass]int)
+	for n := range g.nodes {
+		class, color, priority := g.classify(n)
+		c := nodeClass{class, color}
+		if p, ok := priorities[c]; !ok || priority < p {
+			priorities[c] = priority
+		}
+	}
+	classes := make([]nodeClass, 0, len(priorities))
+	for c := range priorities {
+		classes = append(classes, c)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		ci, cj := classes[i], classes[j]
+		if priorities[ci] != priorities[cj] {
+			return priorities[ci] < priorities[cj]
+		}
+		return ci.name < cj.name
+	})
+	return classes
+}
+
+// writeClassCounts writes a single-line JSON object mapping each class
+// used by the nodes in g, and each overlay that applies to any of them,
+// to the number of nodes in it. As overlays are drawn over the classes,
+// a node may be counted under several names. The names are shortened
+// by countKey, so that the main module is counted as "main" and
+// test-only modules as "testOnly", for example.
+func writeClassCounts(out io.Writer, g *graph) {
+	counts := make(map[string]int)
+	for n := range g.nodes {
+		counts[countKey(g.class(n))]++
+	}
+	for _, o := range g.overlays {
+		if k := len(intersection(o.nodes, g.nodes)); k > 0 {
+			counts[countKey(o.name)] += k
+		}
+	}
+	data, err := json.Marshal(counts)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(out, "%s\n", data)
+}
+
+// countKey returns the name under which -classes-json counts the
+// members of the named class: the name without any Dep or Module suffix.
+func countKey(class string) string {
+	if key, ok := strings.CutSuffix(class, "Dep"); ok && key != "" {
+		return key
+	}
+	if key, ok := strings.CutSuffix(class, "Module"); ok && key != "" {
+		return key
+	}
+	return class
+}
+
+// classDescriptions describes the built-in classes for legends.
+var classDescriptions = map[string]string{
+	"mainModule":  "the main module",
+	"testOnlyDep": "needed only by tests",
+	"regularDep":  "needed by non-test code",
+}
+
+// writeMarkdownLegend writes a markdown list describing
+// the classes used by the nodes in g.
+func writeMarkdownLegend(out io.Writer, g *graph) {
+	fmt.Fprintf(out, "\n**Legend**\n\n")
+	for _, c := range g.classes() {
+		fmt.Fprintf(out, "- `%s` (fill %s)", c.name, c.color)
+		if desc := classDescriptions[c.name]; desc != "" {
+			fmt.Fprintf(out, ": %s", desc)
+		}
+		fmt.Fprintf(out, "\n")
+	}
+	for _, o := range g.overlays {
+		if len(o.nodes) > 0 {
+			fmt.Fprintf(out, "- `%s` (%s)\n", o.name, o.style)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMermaidGolden(t *testing.T) {
+	colors, err := filepath.Abs(filepath.Join("testdata", "fx.colors"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "fx.mmd", mustRun(t, fixture(t, "fx/main"), "-colors-file", colors))
+}
+
+// rescanClassMembers is the way that writeMermaid used to find the
+// members of each class, by going through all the nodes again for
+// every class. It is kept to check classMembers against and to
+// measure the difference.
+func (g *graph) rescanClassMembers(nodes []string) map[nodeClass][]string {
+	members := make(map[nodeClass][]string)
+	for _, c := range g.classes() {
+		for _, name := range nodes {
+			if class, color, _ := g.classify(name); class == c.name && color == c.color {
+				members[c] = append(members[c], name)
+			}
+		}
+	}
+	return members
+}
+
+// colorRuleGraph returns a graph of n modules, each depending on the
+// next, after registering a color rule for each tenth of them. The
+// previous classifiers are restored when the test finishes.
+func colorRuleGraph(tb testing.TB, n int) (*graph, []string) {
+	saved := customClassifiers
+	tb.Cleanup(func() {
+		customClassifiers = saved
+	})
+	var rules []colorRule
+	for i := 0; i < 10; i++ {
+		rules = append(rules, colorRule{regexp.MustCompile(fmt.Sprintf(`/m%d\d*$`, i)), "#123456"})
+	}
+	registerColorRules(rules)
+	var edges [][2]string
+	for i := 0; i+1 < n; i++ {
+		edges = append(edges, [2]string{fmt.Sprintf("example.com/m%d", i), fmt.Sprintf("example.com/m%d", i+1)})
+	}
+	g := edgeGraph(edges...)
+	g.mainMod = "example.com/m0"
+	g.testOnly = map[string]struct{}{"example.com/m1": {}}
+	return g, g.nodeOrder()
+}
+
+func TestClassMembers(t *testing.T) {
+	g, nodes := colorRuleGraph(t, 200)
+	got, want := g.classMembers(nodes), g.rescanClassMembers(nodes)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got members %v, want %v", got, want)
+	}
+}
+
+func BenchmarkClassMembers(b *testing.B) {
+	g, nodes := colorRuleGraph(b, 5000)
+	b.Run("rescan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			g.rescanClassMembers(nodes)
+		}
+	})
+	b.Run("single-pass", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			g.classMembers(nodes)
+		}
+	})
+}
+
+func TestClassesJSON(t *testing.T) {
+	r := runMain(t, fixture(t, "fx/main"), nil, "-classes-json", "-longest-path")
+	if r.failed {
+		t.Fatalf("-classes-json failed:\n%s", r.stderr)
+	}
+	lines := strings.Split(strings.TrimSuffix(r.stderr, "\n"), "\n")
+	var got map[string]int
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &got); err != nil {
+		t.Fatalf("last line of standard error is not a JSON object: %v\n%s", err, r.stderr)
+	}
+	// The four modules of the longest path are also counted
+	// under their own classes.
+	want := map[string]int{"main": 1, "regular": 6, "testOnly": 3, "criticalPath": 4}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got class counts %v, want %v", got, want)
+	}
+	if strings.Contains(r.stdout, `"main":`) {
+		t.Errorf("class counts written to standard output:\n%s", r.stdout)
+	}
+}
+
+func TestCountKey(t *testing.T) {
+	for class, want := range map[string]string{
+		"mainModule":          "main",
+		"testOnlyDep":         "testOnly",
+		"regularDep":          "regular",
+		"retractedVersionDep": "retractedVersion",
+		"criticalPath":        "criticalPath",
+		"owner1":              "owner1",
+		// A name is never shortened to nothing.
+		"Dep": "Dep",
+	} {
+		if got := countKey(class); got != want {
+			t.Errorf("countKey(%q) = %q, want %q", class, got, want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// clipboardCommands holds, for each operating system, the commands
+// that can copy their standard input to the clipboard, in order of
+// preference. Other systems are assumed to be Unix-like.
+var clipboardCommands = map[string][][]string{
+	"darwin":  {{"pbcopy"}},
+	"windows": {{"clip.exe"}},
+	"": {
+		{"wl-copy"},
+		{"xclip", "-selection", "clipboard"},
+		{"xsel", "--clipboard", "--input"},
+	},
+}
+
+// copyToClipboard copies data to the system clipboard using
+// the first available clipboard command.
+func copyToClipboard(data []byte) error {
+	cmds, ok := clipboardCommands[runtime.GOOS]
+	if !ok {
+		cmds = clipboardCommands[""]
+	}
+	for _, args := range cmds {
+		if _, err := exec.LookPath(args[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = bytes.NewReader(data)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s failed: %v: %s", args[0], err, bytes.TrimSpace(out))
+		}
+		return nil
+	}
+	return fmt.Errorf("no clipboard command found")
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// unownedColor is the fill color of modules that no -codeowners rule matches.
+const unownedColor = "#eeeeee"
+
+// ownerRule assigns owners to the module paths matching a pattern.
+type ownerRule struct {
+	pattern *regexp.Regexp
+	owners  string
+}
+
+// readCodeowners reads a CODEOWNERS-style file in which each non-blank
+// line that does not start with # holds a pattern followed by one or
+// more owners. The patterns are matched against module paths as they
+// would be against file paths: * and ? match within a path element,
+// ** matches across elements, and a pattern matches any path within
+// a directory that it matches. As in CODEOWNERS, the last matching
+// line takes precedence.
+func readCodeowners(file string) ([]ownerRule, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var rules []ownerRule
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("%s:%d: no owners for %s", file, lineNum, fields[0])
+		}
+		rules = append(rules, ownerRule{
+			pattern: globPattern(fields[0]),
+			owners:  strings.Join(fields[1:], " "),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// globPattern returns a regular expression matching the paths
+// matched by the given CODEOWNERS pattern.
+func globPattern(glob string) *regexp.Regexp {
+	glob = strings.TrimSuffix(strings.TrimPrefix(glob, "/"), "/")
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("(/.*)?$")
+	return regexp.MustCompile(b.String())
+}
+
+// owners returns the owners given by the last of the rules
+// that matches the module path, or the empty string if none does.
+func owners(rules []ownerRule, path string) string {
+	owner := ""
+	for _, r := range rules {
+		if r.pattern.MatchString(path) {
+			owner = r.owners
+		}
+	}
+	return owner
+}
+
+// registerOwnerColors registers a classifier that colors each module
+// other than the main module by its owners under the given rules, with
+// a class for each distinct set of owners. The colors are spread evenly
+// around the color wheel so that they are easy to tell apart. A catch-all
+// classifier registered after it gives the modules without an owner a
+// neutral color, leaving the test-only ones in their built-in class so
+// that they are still shown as such.
+func registerOwnerColors(rules []ownerRule) {
+	seen := make(map[string]bool)
+	var teams []string
+	for _, r := range rules {
+		if !seen[r.owners] {
+			seen[r.owners] = true
+			teams = append(teams, r.owners)
+		}
+	}
+	classes := make(map[string]nodeClass)
+	for i, team := range teams {
+		class := fmt.Sprintf("owner%d", i+1)
+		classes[team] = nodeClass{class, pastel(float64(i) / float64(len(teams)))}
+		classDescriptions[class] = "owned by " + team
+	}
+	registerClassifier(func(mod moduleInfo) (string, string, bool) {
+		if mod.Main {
+			return "", "", false
+		}
+		c, ok := classes[owners(rules, mod.Path)]
+		return c.name, c.color, ok
+	})
+	classDescriptions["unowned"] = "no owner in " + *flagCodeowners
+	registerClassifier(func(mod moduleInfo) (string, string, bool) {
+		if mod.Main || mod.TestOnly {
+			return "", "", false
+		}
+		return "unowned", unownedColor, true
+	})
+}
+
+// pastel returns a light color with the given hue,
+// expressed as a fraction of the way around the color wheel.
+func pastel(hue float64) string {
+	// Convert from HSL with a saturation of 0.7 and a lightness of 0.85.
+	const s, l = 0.7, 0.85
+	c := (1 - math.Abs(2*l-1)) * s
+	h := hue * 6
+	x := c * (1 - math.Abs(math.Mod(h, 2)-1))
+	var r, g, b float64
+	switch int(h) {
+	case 0:
+		r, g = c, x
+	case 1:
+		r, g = x, c
+	case 2:
+		g, b = c, x
+	case 3:
+		g, b = x, c
+	case 4:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	m := l - c/2
+	return fmt.Sprintf("#%02x%02x%02x", int((r+m)*255+0.5), int((g+m)*255+0.5), int((b+m)*255+0.5))
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCodeownersKeepsTestOnly(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "CODEOWNERS")
+	writeFile(t, file, "example.com/a @core\nexample.com/g @core\n")
+	out := mustRun(t, fixture(t, "fx/main"), "-codeowners", file)
+	// The unowned test-only modules f and x must keep the test-only
+	// class, while g, which is owned, is drawn in its owner's color
+	// and the other modules in the neutral unowned color.
+	for _, line := range []string{
+		"class N0,N6 owner1;",
+		"class N5,N9 testOnlyDep;",
+		"classDef unowned fill:" + unownedColor + ",",
+		"class N1,N2,N3,N4,N8 unowned;",
+		"class N7 mainModule;",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("output does not contain %q:\n%s", line, out)
+		}
+	}
+	if strings.Contains(out, "regularDep") {
+		t.Errorf("output has a regularDep class:\n%s", out)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// colorRule assigns a fill color to the modules matching a pattern.
+type colorRule struct {
+	pattern *regexp.Regexp
+	color   string
+}
+
+// hexColor matches the CSS hex color forms accepted in a colors file.
+var hexColor = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// readColorRules reads color rules from the named file. Each non-blank
+// line that does not start with # has the form regexp=#rrggbb, and
+// assigns the color to each module whose path matches the regexp.
+func readColorRules(file string) ([]colorRule, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var rules []colorRule
+	scanner := bufio.NewScanner(f)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndex(line, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("%s:%d: %q is not of the form REGEXP=COLOR", file, lineNum, line)
+		}
+		pattern, err := regexp.Compile(line[:i])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", file, lineNum, err)
+		}
+		color := line[i+1:]
+		if !hexColor.MatchString(color) {
+			return nil, fmt.Errorf("%s:%d: invalid color %q (want #rrggbb)", file, lineNum, color)
+		}
+		rules = append(rules, colorRule{pattern, color})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// registerColorRules registers a classifier for each of the given rules,
+// so that they take precedence over the built-in classes, with earlier
+// rules taking precedence over later ones. Each rule has a class of its
+// own, named after its position.
+func registerColorRules(rules []colorRule) {
+	for i, r := range rules {
+		class := fmt.Sprintf("colorRule%d", i+1)
+		registerClassifier(func(mod moduleInfo) (string, string, bool) {
+			return class, r.color, r.pattern.MatchString(mod.Path)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadColorRules(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "colors")
+	writeFile(t, file, "# comment\n\n  ^example\\.com/a=b$=#abc  \nexample.com/=#A0b1C2\n")
+	rules, err := readColorRules(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("got %d rules, want 2", len(rules))
+	}
+	// The color follows the last = so that patterns may hold one.
+	if got := rules[0].pattern.String(); got != `^example\.com/a=b$` || rules[0].color != "#abc" {
+		t.Errorf("got first rule %s=%s, want ^example\\.com/a=b$=#abc", got, rules[0].color)
+	}
+	if rules[1].color != "#A0b1C2" {
+		t.Errorf("got second color %s, want #A0b1C2", rules[1].color)
+	}
+
+	for _, test := range []struct {
+		line string
+		want string
+	}{
+		{"example.com", `colors:1: "example.com" is not of the form REGEXP=COLOR`},
+		{"(=#abc", "colors:1: error parsing regexp"},
+		{"x=red", `colors:1: invalid color "red"`},
+		{"x=#abcd", `colors:1: invalid color "#abcd"`},
+	} {
+		writeFile(t, file, test.line+"\n")
+		_, err := readColorRules(file)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("line %q: got error %v, want one containing %q", test.line, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/semver"
+	"golang.org/x/tools/go/packages"
+)
+
+// findConflicts returns any module path that is resolved at more than
+// one version across pkgs, mapped from each version to the sorted
+// modules that import packages at that version. This should not happen
+// in a single build list, but replace chains and workspaces can
+// produce it, and the graph, which identifies modules by path alone,
+// would otherwise hide it.
+func findConflicts(pkgs []*packages.Package) map[string]map[string][]string {
+	importers := make(map[string]map[string]map[string]struct{})
+	add := func(m *packages.Module, from string) {
+		byVersion := importers[m.Path]
+		if byVersion == nil {
+			byVersion = make(map[string]map[string]struct{})
+			importers[m.Path] = byVersion
+		}
+		if byVersion[m.Version] == nil {
+			byVersion[m.Version] = make(map[string]struct{})
+		}
+		if from != "" && from != m.Path {
+			byVersion[m.Version][from] = struct{}{}
+		}
+	}
+	traverse(pkgs, func(p *packages.Package) {
+		if p.Module == nil {
+			return
+		}
+		add(p.Module, "")
+		for _, q := range p.Imports {
+			if q.Module != nil {
+				add(q.Module, p.Module.Path)
+			}
+		}
+	})
+	conflicts := make(map[string]map[string][]string)
+	for path, byVersion := range importers {
+		if len(byVersion) < 2 {
+			continue
+		}
+		conflicts[path] = make(map[string][]string)
+		for v, from := range byVersion {
+			conflicts[path][v] = sortedKeys(from)
+		}
+	}
+	return conflicts
+}
+
+// writeConflicts writes a report of the modules in g that are resolved
+// at more than one version, and reports whether there were any.
+func writeConflicts(out io.Writer, g *graph) bool {
+	for _, path := range sortedKeys(g.conflicts) {
+		byVersion := g.conflicts[path]
+		versions := sortedKeys(byVersion)
+		sort.SliceStable(versions, func(i, j int) bool {
+			return semver.Compare(versions[i], versions[j]) < 0
+		})
+		fmt.Fprintf(out, "%s\n", path)
+		for _, v := range versions {
+			from := "(no importers)"
+			if len(byVersion[v]) > 0 {
+				from = strings.Join(byVersion[v], ", ")
+			}
+			fmt.Fprintf(out, "\t%s: %s\n", v, from)
+		}
+	}
+	if len(g.conflicts) > 0 {
+		log.Printf("found %d modules resolved at more than one version", len(g.conflicts))
+	}
+	return len(g.conflicts) > 0
+}
+
+// brokenStyle is the mermaid style used to highlight modules
+// none of whose packages could be loaded without errors.
+const brokenStyle = "fill:#555555,color:#ffffff,stroke:#f00,stroke-width:2px"
+
+// brokenModules returns the modules in pkgs for which every
+// loaded package has errors.
+func brokenModules(pkgs []*packages.Package) map[string]struct{} {
+	clean := make(map[string]bool)
+	traverse(pkgs, func(p *packages.Package) {
+		if p.Module != nil {
+			clean[p.Module.Path] = clean[p.Module.Path] || len(p.Errors) == 0
+		}
+	})
+	broken := make(map[string]struct{})
+	for m, ok := range clean {
+		if !ok {
+			broken[m] = struct{}{}
+		}
+	}
+	return broken
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// cycleStyle is the link style used for edges that form
+// part of an import cycle within the main module.
+const cycleStyle = "stroke:#f0f,stroke-width:3px"
+
+// checkInternalCycles logs each import cycle among the packages of the
+// main module in g, highlighting its edges, and returns the number of
+// cycles found. Only the edges found without tests are considered,
+// because an external test package may legitimately import packages
+// that themselves import the package under test.
+func checkInternalCycles(g *graph) int {
+	internal := func(n string) bool {
+		return n == g.mainMod || strings.HasPrefix(n, g.mainMod+"/")
+	}
+	edges := make(map[string]map[string]struct{})
+	for from, tos := range g.prodEdges {
+		if !internal(from) {
+			continue
+		}
+		for to := range tos {
+			if internal(to) {
+				if edges[from] == nil {
+					edges[from] = make(map[string]struct{})
+				}
+				edges[from][to] = struct{}{}
+			}
+		}
+	}
+	cycles := 0
+	for _, scc := range stronglyConnected(edges) {
+		if len(scc) < 2 {
+			continue
+		}
+		log.Printf("import cycle: %s", strings.Join(scc, ", "))
+		in := make(map[string]bool)
+		for _, n := range scc {
+			in[n] = true
+		}
+		for _, from := range scc {
+			for to := range edges[from] {
+				if in[to] {
+					g.edgeStyles[edge{from, to}] = cycleStyle
+				}
+			}
+		}
+		cycles++
+	}
+	return cycles
+}
+
+// stronglyConnected returns the strongly connected components of the
+// graph with the given edges, using Tarjan's algorithm. Nodes are
+// visited in name order so that the result is deterministic.
+func stronglyConnected(edges map[string]map[string]struct{}) [][]string {
+	var (
+		index   = make(map[string]int)
+		lowlink = make(map[string]int)
+		onStack = make(map[string]bool)
+		stack   []string
+		sccs    [][]string
+	)
+	var visit func(n string)
+	visit = func(n string) {
+		index[n] = len(index)
+		lowlink[n] = index[n]
+		stack = append(stack, n)
+		onStack[n] = true
+		for _, to := range sortedKeys(edges[n]) {
+			if _, ok := index[to]; !ok {
+				visit(to)
+				lowlink[n] = min(lowlink[n], lowlink[to])
+			} else if onStack[to] {
+				lowlink[n] = min(lowlink[n], index[to])
+			}
+		}
+		if lowlink[n] != index[n] {
+			return
+		}
+		var scc []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			scc = append(scc, top)
+			if top == n {
+				break
+			}
+		}
+		sccs = append(sccs, scc)
+	}
+	for _, n := range sortedKeys(edges) {
+		if _, ok := index[n]; !ok {
+			visit(n)
+		}
+	}
+	return sccs
+}
+
+// checkToolCycles logs each cycle in g that passes through one of the
+// dotted edges to a module providing a tool, highlighting the import
+// edges that complete it, and returns the number of such cycles. A tool
+// that depends, however indirectly, on the module using it cannot be
+// built before that module, which complicates code generation.
+func checkToolCycles(g *graph) int {
+	edges := make(map[string]map[string]struct{})
+	add := func(from, to string) {
+		if edges[from] == nil {
+			edges[from] = make(map[string]struct{})
+		}
+		edges[from][to] = struct{}{}
+	}
+	for from, tos := range g.edges {
+		for to := range tos {
+			add(from, to)
+		}
+	}
+	for _, e := range g.extraEdges {
+		add(e.from, e.to)
+	}
+	cycles := 0
+	for _, scc := range stronglyConnected(edges) {
+		in := make(map[string]bool)
+		for _, n := range scc {
+			in[n] = true
+		}
+		var tools []string
+		for _, e := range g.extraEdges {
+			if in[e.from] && in[e.to] {
+				tools = append(tools, fmt.Sprintf("%s -> %s (%s)", e.from, e.to, e.label))
+			}
+		}
+		if len(tools) == 0 {
+			continue
+		}
+		sort.Strings(scc)
+		log.Printf("tool cycle: %s, through %s", strings.Join(scc, ", "), strings.Join(tools, ", "))
+		for _, from := range scc {
+			for to := range g.edges[from] {
+				if in[to] {
+					g.edgeStyles[edge{from, to}] = cycleStyle
+				}
+			}
+		}
+		cycles++
+	}
+	return cycles
+}
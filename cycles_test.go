@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckToolCycles(t *testing.T) {
+	g := edgeGraph(
+		[2]string{"example.com/main", "example.com/a"},
+		[2]string{"example.com/a", "example.com/b"},
+		[2]string{"example.com/b", "example.com/c"},
+		[2]string{"example.com/c", "example.com/b"},
+		[2]string{"example.com/gen", "example.com/main"},
+	)
+	g.mainMod = "example.com/main"
+	g.edgeStyles = make(map[edge]string)
+	g.extraEdges = []labeledEdge{
+		{edge{"example.com/main", "example.com/gen"}, "generate"},
+		{edge{"example.com/main", "example.com/b"}, "tool"},
+	}
+	// Only the generator depends on the main module. The cycle between
+	// example.com/b and example.com/c does not pass through a tool edge.
+	if got := checkToolCycles(g); got != 1 {
+		t.Fatalf("got %d tool cycles, want 1", got)
+	}
+	want := map[edge]string{{"example.com/gen", "example.com/main"}: cycleStyle}
+	if !reflect.DeepEqual(g.edgeStyles, want) {
+		t.Errorf("got edge styles %v, want %v", g.edgeStyles, want)
+	}
+
+	r := runMain(t, fixture(t, "fx/main"), nil, "-check-tool-cycles")
+	if !r.failed || !strings.Contains(r.stderr, "-check-tool-cycles requires -tools or -scan-generate") {
+		t.Errorf("-check-tool-cycles without -tools did not fail as expected:\n%s", r.stderr)
+	}
+}
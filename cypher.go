@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeCypher writes g as Cypher statements that create a node
+// labeled Module for each module and a DEPENDS_ON relationship
+// for each edge, for loading into a graph database such as Neo4j.
+// MERGE is used throughout so that the statements can be run
+// repeatedly, or against a database holding other graphs.
+func writeCypher(out io.Writer, g *graph) {
+	cg := canonicalize(g)
+	for _, n := range cg.nodes {
+		fmt.Fprintf(out, "MERGE (n:Module {path: %s}) SET n.version = %s, n.main = %v, n.testOnly = %v;\n",
+			cypherQuote(n.path),
+			cypherQuote(n.version),
+			n.path == cg.main,
+			n.testOnly,
+		)
+	}
+	for _, e := range cg.edges {
+		fmt.Fprintf(out, "MATCH (a:Module {path: %s}), (b:Module {path: %s}) MERGE (a)-[r:DEPENDS_ON]->(b) SET r.test = %v;\n",
+			cypherQuote(e.from),
+			cypherQuote(e.to),
+			e.test,
+		)
+	}
+}
+
+// cypherQuote returns s as a single-quoted Cypher string literal.
+func cypherQuote(s string) string {
+	return "'" + cypherEscaper.Replace(s) + "'"
+}
+
+var cypherEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`'`, `\'`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\t", `\t`,
+)
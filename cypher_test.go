@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestCypherGolden(t *testing.T) {
+	checkGolden(t, "fx.cypher", mustRun(t, fixture(t, "fx/main"), "-format", "cypher"))
+}
+
+func TestCypherQuote(t *testing.T) {
+	for s, want := range map[string]string{
+		"example.com/a":  `'example.com/a'`,
+		"":               `''`,
+		`it's`:           `'it\'s'`,
+		`back\slash`:     `'back\\slash'`,
+		"line\nbreak\t.": `'line\nbreak\t.'`,
+	} {
+		if got := cypherQuote(s); got != want {
+			t.Errorf("cypherQuote(%q) = %s, want %s", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// testDepths returns the depth of each test-only module in g within
+// the subgraph of test-only modules. Modules imported directly by a
+// module that is not test-only have depth 1; modules reachable only
+// through other test-only modules are deeper.
+func (g *graph) testDepths() map[string]int {
+	depths := make(map[string]int)
+	q := list.New()
+	for _, from := range sortedKeys(g.edges) {
+		if _, ok := g.testOnly[from]; ok {
+			continue
+		}
+		for to := range g.edges[from] {
+			if _, ok := g.testOnly[to]; ok && depths[to] == 0 {
+				depths[to] = 1
+				q.PushBack(to)
+			}
+		}
+	}
+	for q.Len() > 0 {
+		n := q.Remove(q.Front()).(string)
+		for to := range g.edges[n] {
+			if _, ok := g.testOnly[to]; ok && depths[to] == 0 {
+				depths[to] = depths[n] + 1
+				q.PushBack(to)
+			}
+		}
+	}
+	return depths
+}
+
+// writeDeepTestReport writes each test-only module in g with its
+// depth in the test-only subgraph, deepest first.
+func writeDeepTestReport(out io.Writer, g *graph) {
+	depths := g.testDepths()
+	mods := sortedKeys(depths)
+	sort.SliceStable(mods, func(i, j int) bool {
+		return depths[mods[i]] > depths[mods[j]]
+	})
+	for _, m := range mods {
+		fmt.Fprintf(out, "%d\t%s\n", depths[m], m)
+	}
+}
+
+// propagateTestOnly marks as test-only every module in g that can be
+// reached from the main module only by way of test-only modules, and
+// returns the modules that were not already marked as such. These
+// should agree with the modules found only when loading tests, so
+// any that are returned point to a discrepancy worth investigating.
+func (g *graph) propagateTestOnly() []string {
+	prod := make(map[string]bool)
+	q := list.New()
+	q.PushBack(g.mainMod)
+	for q.Len() > 0 {
+		n := q.Remove(q.Front()).(string)
+		if prod[n] {
+			continue
+		}
+		prod[n] = true
+		for to := range g.edges[n] {
+			if _, ok := g.testOnly[to]; !ok {
+				q.PushBack(to)
+			}
+		}
+	}
+	var added []string
+	for _, n := range sortedKeys(g.nodes) {
+		if _, ok := g.testOnly[n]; !ok && !prod[n] {
+			g.testOnly[n] = struct{}{}
+			added = append(added, n)
+		}
+	}
+	return added
+}
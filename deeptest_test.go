@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPropagateTestOnly(t *testing.T) {
+	g := edgeGraph(
+		[2]string{"main", "a"},
+		[2]string{"main", "f"},
+		[2]string{"a", "c"},
+		[2]string{"f", "g"},
+		[2]string{"g", "h"},
+		// c stays a production module as it is also
+		// reachable through a.
+		[2]string{"g", "c"},
+		// A cycle through a test-only module does not help.
+		[2]string{"h", "g"},
+	)
+	g.mainMod = "main"
+	g.testOnly = map[string]struct{}{"f": {}, "g": {}}
+	if got, want := g.propagateTestOnly(), []string{"h"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got newly test-only %q, want %q", got, want)
+	}
+	if got, want := sortedKeys(g.testOnly), []string{"f", "g", "h"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got test-only %q, want %q", got, want)
+	}
+	// Loading tests finds the same test-only modules in the fixture.
+	r := runMain(t, fixture(t, "fx/main"), nil, "-propagate-test-only")
+	if r.failed || strings.Contains(r.stderr, "marking") {
+		t.Errorf("-propagate-test-only changed the fixture:\n%s", r.stderr)
+	}
+}
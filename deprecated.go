@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/go/packages"
+)
+
+// deprecatedStyle is the mermaid style used to highlight
+// modules whose go.mod marks them as deprecated.
+const deprecatedStyle = "fill:#eeeeee,stroke:#884400,stroke-width:2px,stroke-dasharray:4 2,color:#666666"
+
+// retractedStyle is the mermaid style used to highlight modules
+// whose selected version is retracted by their latest go.mod.
+const retractedStyle = "fill:#ffcccc,stroke:#900,stroke-width:3px"
+
+// readModFile returns the parsed go.mod file of m, or nil
+// if it is not available or cannot be parsed.
+func readModFile(m *packages.Module) *modfile.File {
+	if m.GoMod == "" {
+		return nil
+	}
+	data, err := os.ReadFile(m.GoMod)
+	if err != nil {
+		return nil
+	}
+	f, err := modfile.ParseLax(m.GoMod, data, nil)
+	if err != nil {
+		return nil
+	}
+	return f
+}
+
+// deprecation returns the deprecation message from the module
+// directive of the go.mod file f, or the empty string if the
+// module is not deprecated.
+func deprecation(f *modfile.File) string {
+	if f == nil || f.Module == nil {
+		return ""
+	}
+	return f.Module.Deprecated
+}
+
+// retractedVersions returns the rationale for the retraction of each
+// module in the build list of the main module in dir whose selected
+// version is retracted. The go command is asked rather than reading
+// the selected version's go.mod because only the retract directives
+// in the go.mod of a module's latest version count, and finding that
+// version may need the network. Modules for which that fails are
+// treated as not retracted.
+func retractedVersions(dir string) (map[string]string, error) {
+	cmd := exec.Command("go", "list", "-m", "-e", "-json", "-retracted", "all")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), loadEnv()...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list -m -retracted: %v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	retracted := make(map[string]string)
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var m struct {
+			Path      string
+			Retracted []string
+		}
+		if err := dec.Decode(&m); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("cannot decode go list output: %v", err)
+		}
+		if len(m.Retracted) > 0 {
+			retracted[m.Path] = strings.Join(m.Retracted, "; ")
+		}
+	}
+	return retracted, nil
+}
+
+// tooltipEscaper removes the characters that cannot appear
+// inside a quoted mermaid tooltip.
+var tooltipEscaper = strings.NewReplacer(
+	`"`, "'",
+	"\n", " ",
+	"\r", " ",
+)
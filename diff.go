@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// changedModules returns the modules in g that were added or whose
+// version changed relative to old. It also logs a summary of the
+// changes, including the modules that old has but g does not, as
+// those cannot be shown in g itself.
+func changedModules(old, g *graph) map[string]struct{} {
+	changed := make(map[string]struct{})
+	added, bumped := 0, 0
+	for n := range g.nodes {
+		if _, ok := old.nodes[n]; !ok {
+			added++
+		} else if old.versions[n] == g.versions[n] {
+			continue
+		} else {
+			bumped++
+		}
+		changed[n] = struct{}{}
+	}
+	var removed []string
+	for _, n := range sortedKeys(old.nodes) {
+		if _, ok := g.nodes[n]; !ok {
+			removed = append(removed, n)
+		}
+	}
+	log.Printf("note: %d modules changed (%d added, %d version changed, %d removed)", added+bumped+len(removed), added, bumped, len(removed))
+	if len(removed) > 0 {
+		log.Printf("note: removed modules: %s", strings.Join(removed, ", "))
+	}
+	return changed
+}
+
+// neighborhood returns the given set of nodes together with
+// all the nodes that are directly connected to any of them.
+func (g *graph) neighborhood(set map[string]struct{}) map[string]struct{} {
+	result := make(map[string]struct{})
+	for from, tos := range g.edges {
+		for to := range tos {
+			_, fromIn := set[from]
+			_, toIn := set[to]
+			if fromIn || toIn {
+				result[from] = struct{}{}
+				result[to] = struct{}{}
+			}
+		}
+	}
+	for n := range set {
+		result[n] = struct{}{}
+	}
+	return result
+}
+
+// dirtyModules returns the modules whose require lines in the
+// current module's go.mod differ between HEAD and the working tree.
+func dirtyModules() (map[string]struct{}, error) {
+	goMod, err := goModFile()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(goMod)
+	cmd := exec.Command("git", "rev-parse", "--is-inside-work-tree")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%s is not in a git repository: %s", dir, bytes.TrimSpace(out))
+	}
+	cmd = exec.Command("git", "diff", "HEAD", "--", filepath.Base(goMod))
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git diff: %v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return changedRequirements(string(out)), nil
+}
+
+// changedRequirements returns the modules named by the added or
+// removed require lines in the given unified diff of a go.mod file.
+// Both the single-line form and lines within a require block are
+// recognized; lines that do not look like a requirement are ignored.
+func changedRequirements(diff string) map[string]struct{} {
+	mods := make(map[string]struct{})
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "+++") || strings.HasPrefix(line, "---") {
+			continue
+		}
+		if !strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "-") {
+			continue
+		}
+		line, _, _ = strings.Cut(line[1:], "//")
+		line = strings.TrimPrefix(strings.TrimSpace(line), "require ")
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.HasPrefix(fields[1], "v") {
+			mods[fields[0]] = struct{}{}
+		}
+	}
+	return mods
+}
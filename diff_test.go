@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChangedRequirements(t *testing.T) {
+	diff := `diff --git a/go.mod b/go.mod
+--- a/go.mod
++++ b/go.mod
+@@ -3,8 +3,9 @@
+ go 1.22
+-require example.com/one v1.0.0
++require example.com/one v1.1.0
+ require (
+ 	example.com/same v1.0.0
+-	example.com/two v1.0.0 // indirect
++	example.com/three v0.1.0
++// a comment
++replace example.com/four => ../four
+ )
+`
+	want := []string{"example.com/one", "example.com/three", "example.com/two"}
+	if got := sortedKeys(changedRequirements(diff)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got changed modules %q, want %q", got, want)
+	}
+}
+
+func TestDirtyOnly(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	root := t.TempDir()
+	copyDir(t, root, fixture(t, "fx"))
+	dir := filepath.Join(root, "main")
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %q: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	goMod := filepath.Join(dir, "go.mod")
+	data, err := os.ReadFile(goMod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, goMod, strings.Replace(string(data), "example.com/d v1.0.0 // indirect", "example.com/d v1.0.1 // indirect", 1))
+	r := runMain(t, dir, nil, "-dirty-only", "-format", "porcelain")
+	if r.failed {
+		t.Fatalf("-dirty-only failed:\n%s", r.stderr)
+	}
+	// Only example.com/b is next to example.com/d.
+	want := []string{
+		"N\texample.com/b\tregularDep\tv1.0.0",
+		"N\texample.com/d\tregularDep\tv1.0.1",
+		"E\texample.com/b\texample.com/d",
+	}
+	if got := porcelainLines(r.stdout); !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if !strings.Contains(r.stderr, "note: 1 modules with uncommitted requirement changes\n") {
+		t.Errorf("log does not give the number of changed modules:\n%s", r.stderr)
+	}
+}
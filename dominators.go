@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// dominatorStyle is the style used to highlight modules that
+// are the only way by which other modules are reached.
+const dominatorStyle = "stroke:#06c,stroke-width:3px"
+
+// immediateDominators returns the immediate dominator of each node
+// reachable from root in g, other than root itself. A node m dominates
+// n if every path from root to n passes through m; the immediate
+// dominator of n is the dominator of n closest to it. It uses the
+// iterative algorithm of Cooper, Harvey and Kennedy.
+func (g *graph) immediateDominators(root string) map[string]string {
+	// Number the nodes in reverse postorder.
+	var order []string
+	visited := make(map[string]bool)
+	var visit func(n string)
+	visit = func(n string) {
+		visited[n] = true
+		for _, to := range sortedKeys(g.edges[n]) {
+			if !visited[to] {
+				visit(to)
+			}
+		}
+		order = append(order, n)
+	}
+	visit(root)
+	rpo := make(map[string]int)
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+	for i, n := range order {
+		rpo[n] = i
+	}
+	preds := make(map[string][]string)
+	for from, tos := range g.edges {
+		if !visited[from] {
+			continue
+		}
+		for to := range tos {
+			preds[to] = append(preds[to], from)
+		}
+	}
+	idom := map[string]string{root: root}
+	intersect := func(a, b string) string {
+		for a != b {
+			for rpo[a] > rpo[b] {
+				a = idom[a]
+			}
+			for rpo[b] > rpo[a] {
+				b = idom[b]
+			}
+		}
+		return a
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, n := range order[1:] {
+			newIdom := ""
+			for _, p := range preds[n] {
+				if _, ok := idom[p]; !ok {
+					continue
+				}
+				if newIdom == "" {
+					newIdom = p
+				} else {
+					newIdom = intersect(p, newIdom)
+				}
+			}
+			if idom[n] != newIdom {
+				idom[n] = newIdom
+				changed = true
+			}
+		}
+	}
+	delete(idom, root)
+	return idom
+}
+
+// dominated returns, for each module in g other than the main module
+// that dominates any others, the modules that it dominates. These
+// are the modules that would no longer be needed were it removed.
+func (g *graph) dominated() map[string][]string {
+	idom := g.immediateDominators(g.mainMod)
+	dominated := make(map[string][]string)
+	for _, n := range sortedKeys(idom) {
+		for d := idom[n]; d != g.mainMod; d = idom[d] {
+			dominated[d] = append(dominated[d], n)
+		}
+	}
+	return dominated
+}
+
+// showDominators logs the modules that each module in g dominates,
+// those dominating the most first, and highlights and annotates the
+// modules that dominate any others.
+func showDominators(g *graph) {
+	if _, ok := g.nodes[g.mainMod]; !ok {
+		log.Printf("dominators: main module not in graph")
+		return
+	}
+	dominated := g.dominated()
+	mods := sortedKeys(dominated)
+	sort.SliceStable(mods, func(i, j int) bool {
+		return len(dominated[mods[i]]) > len(dominated[mods[j]])
+	})
+	nodes := make(map[string]struct{})
+	for _, m := range mods {
+		log.Printf("dominator: %s (%d): %s", m, len(dominated[m]), strings.Join(dominated[m], ", "))
+		nodes[m] = struct{}{}
+		g.notes[m] = append(g.notes[m], fmt.Sprintf("(dominates %d)", len(dominated[m])))
+	}
+	g.overlays = append(g.overlays, classOverlay{
+		name:  "dominator",
+		style: dominatorStyle,
+		nodes: nodes,
+	})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDominated(t *testing.T) {
+	g := edgeGraph(
+		[2]string{"main", "a"},
+		[2]string{"main", "b"},
+		[2]string{"a", "c"},
+		[2]string{"b", "c"},
+		[2]string{"c", "d"},
+		[2]string{"d", "e"},
+		// A cycle back into the dominated subtree changes nothing.
+		[2]string{"e", "c"},
+		// Nor does an edge from a module that is not reachable.
+		[2]string{"other", "d"},
+	)
+	g.mainMod = "main"
+	idom := g.immediateDominators("main")
+	wantIdom := map[string]string{"a": "main", "b": "main", "c": "main", "d": "c", "e": "d"}
+	if !reflect.DeepEqual(idom, wantIdom) {
+		t.Errorf("got immediate dominators %v, want %v", idom, wantIdom)
+	}
+	want := map[string][]string{
+		"c": {"d", "e"},
+		"d": {"e"},
+	}
+	if got := g.dominated(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got dominated %v, want %v", got, want)
+	}
+}
+
+func TestShowDominators(t *testing.T) {
+	g := edgeGraph(
+		[2]string{"main", "a"},
+		[2]string{"main", "b"},
+		[2]string{"a", "c"},
+		[2]string{"b", "c"},
+		[2]string{"c", "d"},
+		[2]string{"d", "e"},
+	)
+	g.mainMod = "main"
+	g.notes = make(map[string][]string)
+	showDominators(g)
+	wantNotes := map[string][]string{
+		"c": {"(dominates 2)"},
+		"d": {"(dominates 1)"},
+	}
+	if !reflect.DeepEqual(g.notes, wantNotes) {
+		t.Errorf("got notes %v, want %v", g.notes, wantNotes)
+	}
+	if len(g.overlays) != 1 || g.overlays[0].name != "dominator" {
+		t.Fatalf("got overlays %v, want just the dominator overlay", g.overlays)
+	}
+	if got, want := sortedKeys(g.overlays[0].nodes), []string{"c", "d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got highlighted %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"log"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// dropExampleTests returns pkgs with all test packages whose test files
+// contain only Example functions removed, both from pkgs itself and from
+// the imports of the packages that remain. Such examples usually serve as
+// documentation, so the modules that they use are not considered to be
+// genuine test dependencies.
+func dropExampleTests(pkgs []*packages.Package) []*packages.Package {
+	drop := make(map[*packages.Package]bool)
+	var testMains []*packages.Package
+	traverse(pkgs, func(p *packages.Package) {
+		switch {
+		case isTestMain(p):
+			testMains = append(testMains, p)
+		case isTestVariant(p) && onlyExamples(p):
+			drop[p] = true
+		}
+	})
+	// A generated test main is only needed while
+	// some of the tests that it runs remain.
+	for _, p := range testMains {
+		keep := false
+		for _, imp := range p.Imports {
+			if imp != nil && isTestVariant(imp) && !drop[imp] {
+				keep = true
+			}
+		}
+		if !keep {
+			drop[p] = true
+		}
+	}
+	if len(drop) == 0 {
+		return pkgs
+	}
+	traverse(pkgs, func(p *packages.Package) {
+		for path, imp := range p.Imports {
+			if drop[imp] {
+				delete(p.Imports, path)
+			}
+		}
+	})
+	var kept []*packages.Package
+	for _, p := range pkgs {
+		if !drop[p] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// onlyExamples reports whether p has test files and none of them
+// contain any Test, Benchmark or Fuzz functions.
+func onlyExamples(p *packages.Package) bool {
+	files := testFiles(p)
+	if len(files) == 0 {
+		return false
+	}
+	fset := token.NewFileSet()
+	for _, file := range files {
+		f, err := parser.ParseFile(fset, file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			log.Printf("cannot parse test file: %v", err)
+			return false
+		}
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv != nil {
+				continue
+			}
+			for _, prefix := range []string{"Test", "Benchmark", "Fuzz"} {
+				if strings.HasPrefix(fn.Name.Name, prefix) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// ignoredTestFiles holds the pattern given by -ignore-test-files, if any.
+var ignoredTestFiles *regexp.Regexp
+
+// dropIgnoredTestImports removes from the test packages in pkgs each
+// import that is made only by test files whose base names match pattern,
+// so that the dependencies of, say, integration tests can be left out.
+// It returns pkgs without the packages, and their tests, that are
+// present only because those test files import them.
+func dropIgnoredTestImports(pkgs []*packages.Package, pattern *regexp.Regexp) []*packages.Package {
+	fset := token.NewFileSet()
+	var dropped []*packages.Package
+	traverse(pkgs, func(p *packages.Package) {
+		if !isTestVariant(p) {
+			return
+		}
+		ignored := false
+		used := make(map[string]bool)
+		for _, file := range p.GoFiles {
+			if strings.HasSuffix(file, "_test.go") && pattern.MatchString(filepath.Base(file)) {
+				ignored = true
+				continue
+			}
+			f, err := parser.ParseFile(fset, file, nil, parser.ImportsOnly)
+			if err != nil {
+				log.Printf("cannot parse %s: %v", file, err)
+				return
+			}
+			for _, spec := range f.Imports {
+				if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+					used[path] = true
+				}
+			}
+		}
+		if !ignored {
+			return
+		}
+		for path, imp := range p.Imports {
+			if !used[path] {
+				delete(p.Imports, path)
+				dropped = append(dropped, imp)
+			}
+		}
+	})
+	// The packages loaded because of the "all" pattern include those
+	// imported by the ignored files along with their own tests, so
+	// drop any that nothing else still reaches.
+	basePath := func(p *packages.Package) string {
+		return strings.TrimSuffix(strings.TrimSuffix(p.PkgPath, ".test"), "_test")
+	}
+	candidates := make(map[string]bool)
+	traverse(dropped, func(p *packages.Package) {
+		candidates[basePath(p)] = true
+	})
+	var others []*packages.Package
+	for _, p := range pkgs {
+		if !candidates[basePath(p)] {
+			others = append(others, p)
+		}
+	}
+	reached := make(map[string]bool)
+	traverse(others, func(p *packages.Package) {
+		reached[basePath(p)] = true
+	})
+	var kept []*packages.Package
+	for _, p := range pkgs {
+		if path := basePath(p); !candidates[path] || reached[path] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// testFiles returns the _test.go files of p.
+func testFiles(p *packages.Package) []string {
+	var files []string
+	for _, f := range p.GoFiles {
+		if strings.HasSuffix(f, "_test.go") {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// directTestModules returns the modules whose packages are imported
+// directly by the test files of any package in pkgs, as opposed to
+// being reached only through other packages.
+func directTestModules(pkgs []*packages.Package) map[string]struct{} {
+	byID := make(map[string]*packages.Package)
+	traverse(pkgs, func(p *packages.Package) {
+		byID[p.ID] = p
+	})
+	mods := make(map[string]struct{})
+	for _, p := range byID {
+		if len(testFiles(p)) == 0 {
+			continue
+		}
+		// An internal test package also imports everything that
+		// the package's regular files do, so discount those.
+		var regular map[string]*packages.Package
+		if base := byID[p.PkgPath]; base != nil && base != p {
+			regular = base.Imports
+		}
+		for path, imp := range p.Imports {
+			if imp == nil || imp.Module == nil || imp.PkgPath == strings.TrimSuffix(p.PkgPath, "_test") {
+				continue
+			}
+			if _, ok := regular[path]; ok {
+				continue
+			}
+			mods[imp.Module.Path] = struct{}{}
+		}
+	}
+	return mods
+}
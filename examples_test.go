@@ -0,0 +1,53 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIgnoreTestFiles(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	out := mustRun(t, dir, "-ignore-test-files", `^a_test\.go$`, "-format", "porcelain")
+	if strings.Contains(out, "example.com/f") || strings.Contains(out, "example.com/g") {
+		t.Errorf("modules imported only by a_test.go are still present:\n%s", out)
+	}
+	if !strings.Contains(out, "E\texample.com/c\texample.com/x\n") {
+		t.Errorf("import made by another test file has gone:\n%s", out)
+	}
+
+	// An import is kept if some test file that does not
+	// match the pattern makes it too.
+	root := t.TempDir()
+	copyDir(t, root, fixture(t, "fx"))
+	writeFile(t, filepath.Join(root, "a", "more_test.go"), "package a\n\nimport (\n\t\"testing\"\n\n\t\"example.com/f\"\n)\n\nfunc TestMore(t *testing.T) { f.F() }\n")
+	out = mustRun(t, filepath.Join(root, "main"), "-ignore-test-files", `^a_test\.go$`, "-format", "porcelain")
+	if !strings.Contains(out, "E\texample.com/a\texample.com/f\n") {
+		t.Errorf("import also made by more_test.go has gone:\n%s", out)
+	}
+}
+
+func TestIgnoreExamples(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	out := mustRun(t, dir, "-format", "porcelain")
+	if !strings.Contains(out, "E\texample.com/c\texample.com/x\n") {
+		t.Fatalf("the example of example.com/c does not use example.com/x:\n%s", out)
+	}
+	out = mustRun(t, dir, "-format", "porcelain", "-ignore-examples")
+	if strings.Contains(out, "example.com/x") {
+		t.Errorf("module used only by an example is still present:\n%s", out)
+	}
+	if !strings.Contains(out, "N\texample.com/f\ttestOnlyDep\t") {
+		t.Errorf("module used by a Test function has gone:\n%s", out)
+	}
+
+	// A test package with a Test function as well as
+	// an example keeps the dependencies of both.
+	root := t.TempDir()
+	copyDir(t, root, fixture(t, "fx"))
+	writeFile(t, filepath.Join(root, "c", "c_test.go"), "package c_test\n\nimport \"testing\"\n\nfunc TestC(t *testing.T) {}\n")
+	out = mustRun(t, filepath.Join(root, "main"), "-format", "porcelain", "-ignore-examples")
+	if !strings.Contains(out, "E\texample.com/c\texample.com/x\n") {
+		t.Errorf("module used by an example alongside a test has gone:\n%s", out)
+	}
+}
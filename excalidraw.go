@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+)
+
+// excalidrawElement holds the fields of an Excalidraw scene element
+// that writeExcalidraw sets. Excalidraw fills in defaults for the
+// rest when the scene is opened.
+type excalidrawElement struct {
+	ID              string             `json:"id"`
+	Type            string             `json:"type"`
+	X               int                `json:"x"`
+	Y               int                `json:"y"`
+	Width           int                `json:"width"`
+	Height          int                `json:"height"`
+	StrokeColor     string             `json:"strokeColor"`
+	BackgroundColor string             `json:"backgroundColor"`
+	FillStyle       string             `json:"fillStyle"`
+	Roughness       int                `json:"roughness"`
+	BoundElements   []excalidrawBound  `json:"boundElements,omitempty"`
+	Text            string             `json:"text,omitempty"`
+	FontSize        int                `json:"fontSize,omitempty"`
+	FontFamily      int                `json:"fontFamily,omitempty"`
+	ContainerID     string             `json:"containerId,omitempty"`
+	VerticalAlign   string             `json:"verticalAlign,omitempty"`
+	Points          [][2]int           `json:"points,omitempty"`
+	StartBinding    *excalidrawBinding `json:"startBinding,omitempty"`
+	EndBinding      *excalidrawBinding `json:"endBinding,omitempty"`
+	EndArrowhead    string             `json:"endArrowhead,omitempty"`
+	CustomData      map[string]string  `json:"customData,omitempty"`
+}
+
+type excalidrawBound struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+}
+
+type excalidrawBinding struct {
+	ElementID string  `json:"elementId"`
+	Focus     float64 `json:"focus"`
+	Gap       int     `json:"gap"`
+}
+
+// writeExcalidraw writes g as an Excalidraw scene, so that it can be
+// opened and rearranged by hand. Each module is a rectangle colored
+// by its class and containing its label, placed by the same layered
+// layout as the svg format, and each edge is an arrow bound to the
+// rectangles at either end, so that it follows them when they move.
+func writeExcalidraw(out io.Writer, g *graph) {
+	pos, width, _, _ := g.layout()
+	nodes := sortedKeys(g.nodes)
+	ids := make(map[string]string)
+	for i, n := range nodes {
+		ids[n] = fmt.Sprintf("node%d", i)
+	}
+	bound := make(map[string][]excalidrawBound)
+	var arrows []excalidrawElement
+	for _, from := range nodes {
+		for _, to := range g.successors(from) {
+			id := fmt.Sprintf("edge%d", len(arrows))
+			p, q := pos[from], pos[to]
+			x, y := p.x+width[from], p.y+svgNodeHeight/2
+			dx, dy := q.x-x, q.y+svgNodeHeight/2-y
+			arrows = append(arrows, excalidrawElement{
+				ID:           id,
+				Type:         "arrow",
+				X:            x,
+				Y:            y,
+				Width:        max(dx, -dx),
+				Height:       max(dy, -dy),
+				StrokeColor:  "#333333",
+				FillStyle:    "solid",
+				Points:       [][2]int{{0, 0}, {dx, dy}},
+				StartBinding: &excalidrawBinding{ElementID: ids[from], Gap: 1},
+				EndBinding:   &excalidrawBinding{ElementID: ids[to], Gap: 1},
+				EndArrowhead: "arrow",
+			})
+			bound[from] = append(bound[from], excalidrawBound{id, "arrow"})
+			bound[to] = append(bound[to], excalidrawBound{id, "arrow"})
+		}
+	}
+	elements := []excalidrawElement{}
+	for _, n := range nodes {
+		p := pos[n]
+		class, color, _ := g.classify(n)
+		text := ids[n] + "-label"
+		elements = append(elements, excalidrawElement{
+			ID:              ids[n],
+			Type:            "rectangle",
+			X:               p.x,
+			Y:               p.y,
+			Width:           width[n],
+			Height:          svgNodeHeight,
+			StrokeColor:     "#333333",
+			BackgroundColor: color,
+			FillStyle:       "solid",
+			BoundElements:   append([]excalidrawBound{{text, "text"}}, bound[n]...),
+			CustomData:      map[string]string{"module": n, "class": class},
+		}, excalidrawElement{
+			ID:            text,
+			Type:          "text",
+			X:             p.x + svgNodePad,
+			Y:             p.y + svgNodePad/2,
+			Width:         width[n] - 2*svgNodePad,
+			Height:        svgNodeHeight - svgNodePad,
+			StrokeColor:   "#1e1e1e",
+			FillStyle:     "solid",
+			Text:          g.label(n),
+			FontSize:      12,
+			FontFamily:    3, // monospace
+			ContainerID:   ids[n],
+			VerticalAlign: "middle",
+		})
+	}
+	elements = append(elements, arrows...)
+	scene := struct {
+		Type     string              `json:"type"`
+		Version  int                 `json:"version"`
+		Source   string              `json:"source"`
+		Elements []excalidrawElement `json:"elements"`
+		AppState map[string]string   `json:"appState"`
+		Files    struct{}            `json:"files"`
+	}{
+		Type:     "excalidraw",
+		Version:  2,
+		Source:   "https://github.com/rogpeppe/gotestdeps",
+		Elements: elements,
+		AppState: map[string]string{"viewBackgroundColor": "#ffffff"},
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(scene); err != nil {
+		log.Fatalf("cannot write excalidraw scene: %v", err)
+	}
+}
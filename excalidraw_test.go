@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestExcalidraw(t *testing.T) {
+	var scene struct {
+		Type     string              `json:"type"`
+		Elements []excalidrawElement `json:"elements"`
+	}
+	out := mustRun(t, fixture(t, "fx/main"), "-format", "excalidraw")
+	if err := json.Unmarshal([]byte(out), &scene); err != nil {
+		t.Fatal(err)
+	}
+	if scene.Type != "excalidraw" {
+		t.Errorf("got scene type %q", scene.Type)
+	}
+	byID := make(map[string]excalidrawElement)
+	count := make(map[string]int)
+	for _, e := range scene.Elements {
+		if _, ok := byID[e.ID]; ok {
+			t.Errorf("duplicate element id %q", e.ID)
+		}
+		byID[e.ID] = e
+		count[e.Type]++
+	}
+	if count["rectangle"] != 10 || count["text"] != 10 || count["arrow"] != 10 {
+		t.Errorf("got %v elements, want 10 each of rectangles, texts and arrows", count)
+	}
+	isBound := func(rect, id string) bool {
+		for _, b := range byID[rect].BoundElements {
+			if b.ID == id {
+				return true
+			}
+		}
+		return false
+	}
+	modules := make(map[string]string)
+	for _, e := range scene.Elements {
+		switch e.Type {
+		case "rectangle":
+			modules[e.ID] = e.CustomData["module"]
+		case "text":
+			if byID[e.ContainerID].Type != "rectangle" || !isBound(e.ContainerID, e.ID) {
+				t.Errorf("text %q is not bound to its container %q", e.ID, e.ContainerID)
+			}
+		case "arrow":
+			for _, end := range []*excalidrawBinding{e.StartBinding, e.EndBinding} {
+				if end == nil || byID[end.ElementID].Type != "rectangle" || !isBound(end.ElementID, e.ID) {
+					t.Errorf("arrow %q is not bound at both ends", e.ID)
+				}
+			}
+		}
+	}
+	if modules["node0"] != "example.com/a" {
+		t.Errorf("node0 is module %q, want example.com/a", modules["node0"])
+	}
+	var found bool
+	for _, e := range scene.Elements {
+		if e.Type == "arrow" && modules[e.StartBinding.ElementID] == "example.com/main" && modules[e.EndBinding.ElementID] == "example.com/t" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("no arrow from example.com/main to example.com/t")
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// explainTestOnly writes to out the shortest chain of imports by which
+// test code in pkgs pulls in the given module, noting for each hop
+// whether the import comes from test files or from regular code.
+func explainTestOnly(out io.Writer, g *graph, pkgs []*packages.Package, module string) error {
+	if _, ok := g.nodes[module]; !ok {
+		return fmt.Errorf("module %q not found in dependency graph", module)
+	}
+	if _, ok := g.testOnly[module]; !ok {
+		return fmt.Errorf("module %q is not test-only", module)
+	}
+	byID := make(map[string]*packages.Package)
+	traverse(pkgs, func(p *packages.Package) {
+		byID[p.ID] = p
+	})
+	// Search breadth-first from all the test packages at once
+	// so that the first path found is the shortest.
+	from := make(map[*packages.Package]*packages.Package)
+	q := list.New()
+	for _, id := range sortedKeys(byID) {
+		if p := byID[id]; isTestVariant(p) && !isTestMain(p) {
+			from[p] = nil
+			q.PushBack(p)
+		}
+	}
+	for q.Len() > 0 {
+		p := q.Remove(q.Front()).(*packages.Package)
+		if modulePathOf(p) == module {
+			writeImportChain(out, byID, from, p)
+			return nil
+		}
+		for _, path := range sortedKeys(p.Imports) {
+			imp := p.Imports[path]
+			if _, ok := from[imp]; ok || imp == nil {
+				continue
+			}
+			from[imp] = p
+			q.PushBack(imp)
+		}
+	}
+	return fmt.Errorf("no import chain from test code to %s found", module)
+}
+
+// writeImportChain writes the chain of imports that leads to p,
+// as recorded in from.
+func writeImportChain(out io.Writer, byID map[string]*packages.Package, from map[*packages.Package]*packages.Package, p *packages.Package) {
+	var chain []*packages.Package
+	for ; p != nil; p = from[p] {
+		chain = append(chain, p)
+	}
+	fmt.Fprintf(out, "%s (module %s)\n", chain[len(chain)-1].ID, modulePathOf(chain[len(chain)-1]))
+	for i := len(chain) - 2; i >= 0; i-- {
+		importer, imp := chain[i+1], chain[i]
+		kind := "regular"
+		if isTestImport(byID, importer, imp) {
+			kind = "_test"
+		}
+		fmt.Fprintf(out, "\t-> %s (module %s) via %s import\n", imp.ID, modulePathOf(imp), kind)
+	}
+}
+
+// isTestImport reports whether the import of imp by p comes from test
+// files: either p is an external test package, or p is a package
+// augmented with its test files whose non-test form lacks the import.
+func isTestImport(byID map[string]*packages.Package, p, imp *packages.Package) bool {
+	if !isTestVariant(p) {
+		return false
+	}
+	plain := byID[p.PkgPath]
+	if plain == nil || plain == p {
+		return true
+	}
+	for _, pimp := range plain.Imports {
+		if pimp != nil && pimp.PkgPath == imp.PkgPath {
+			return false
+		}
+	}
+	return true
+}
+
+// explainColor writes to out a short explanation of why the given
+// module is drawn in the color that it is.
+func explainColor(out io.Writer, g *graph, module string) error {
+	if _, ok := g.nodes[module]; !ok {
+		return fmt.Errorf("module %q not found in dependency graph", module)
+	}
+	class, color, priority := g.classify(module)
+	fmt.Fprintf(out, "%s is drawn as %s (%s) because ", module, class, color)
+	_, testOnly := g.testOnly[module]
+	switch {
+	case priority < len(customClassifiers):
+		fmt.Fprintf(out, "a custom classifier chose that class for it.\n")
+	case module == g.mainMod:
+		fmt.Fprintf(out, "it is the main module.\n")
+	case testOnly && *flagTestOnlyMode == "direct":
+		fmt.Fprintf(out, "it is needed only when tests are loaded and is imported directly by test files.\n")
+	case testOnly:
+		fmt.Fprintf(out, "it is needed only when tests are loaded: no package found without tests belongs to it.\n")
+	case *flagTestOnlyMode == "direct" && g.isTestReached(module):
+		fmt.Fprintf(out, "although it is needed only when tests are loaded, it is not imported directly by test files.\n")
+	default:
+		fmt.Fprintf(out, "it is needed even when tests are not loaded.\n")
+	}
+	if *flagMainTestsAsProd && !testOnly {
+		fmt.Fprintf(out, "Note that -count-main-tests-as-prod counts modules used by the main module's tests as regular dependencies.\n")
+	}
+	return nil
+}
+
+// isTestReached reports whether module is reached only through
+// edges found when tests are loaded.
+func (g *graph) isTestReached(module string) bool {
+	for from := range g.prodEdges {
+		if _, ok := g.prodEdges[from][module]; ok {
+			return false
+		}
+	}
+	return true
+}
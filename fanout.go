@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sort"
+)
+
+// uniqueFanout returns, for each of the given direct requirements in g,
+// the number of modules that it contributes uniquely, which are those
+// reachable from it but from no other direct requirement, and the
+// total number of modules reachable from it. Each count includes the
+// requirement itself, if it is not reachable from another one, and
+// excludes the main module.
+func (g *graph) uniqueFanout(direct map[string]struct{}) (unique, total map[string]int) {
+	reach := make(map[string]map[string]struct{})
+	reachers := make(map[string]int)
+	for d := range intersection(direct, g.nodes) {
+		reach[d] = g.reachable(d)
+		delete(reach[d], g.mainMod)
+		for n := range reach[d] {
+			reachers[n]++
+		}
+	}
+	unique = make(map[string]int)
+	total = make(map[string]int)
+	for d, mods := range reach {
+		total[d] = len(mods)
+		unique[d] = 0
+		for n := range mods {
+			if reachers[n] == 1 {
+				unique[d]++
+			}
+		}
+	}
+	return unique, total
+}
+
+// writeFanoutReport writes each direct requirement of the current
+// module with the number of modules it contributes uniquely and the
+// number it reaches in all, most unique first.
+func writeFanoutReport(out io.Writer, g *graph) {
+	direct, err := directRequirements()
+	if err != nil {
+		log.Fatalf("cannot read requirements: %v", err)
+	}
+	unique, total := g.uniqueFanout(direct)
+	mods := sortedKeys(unique)
+	sort.SliceStable(mods, func(i, j int) bool {
+		return unique[mods[i]] > unique[mods[j]]
+	})
+	for _, m := range mods {
+		fmt.Fprintf(out, "%d\t%d\t%s\n", unique[m], total[m], m)
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+import "testing"
+
+func TestFanoutReport(t *testing.T) {
+	// Both example.com/a and example.com/b reach example.com/c and
+	// example.com/x, so neither counts them as its own.
+	want := "" +
+		"3\t5\texample.com/a\n" +
+		"2\t4\texample.com/b\n" +
+		"2\t2\texample.com/t\n"
+	if got := mustRun(t, fixture(t, "fx/main"), "-fanout-report"); got != want {
+		t.Errorf("got report:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// generateToolStyle is the mermaid style used for modules providing
+// tools run by go:generate directives in the main module.
+const generateToolStyle = "stroke:#969,stroke-width:2px,stroke-dasharray:2 2"
+
+// generateTools returns the package paths run with "go run" by the
+// go:generate directives in the Go files of the main module rooted
+// at dir. Nested modules, vendor and testdata directories and
+// directories that the go command ignores are skipped, as are
+// tools given as file names or relative paths, which are part of
+// the main module itself.
+func generateTools(dir string) (map[string]struct{}, error) {
+	tools := make(map[string]struct{})
+	fset := token.NewFileSet()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != dir && (name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); path != dir && err == nil {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			log.Printf("cannot parse %s: %v", path, err)
+			return nil
+		}
+		for _, cg := range f.Comments {
+			for _, c := range cg.List {
+				if pkg := goRunPackage(c.Text); pkg != "" {
+					tools[pkg] = struct{}{}
+				}
+			}
+		}
+		return nil
+	})
+	return tools, err
+}
+
+// goRunPackage returns the path of the package run by the given
+// comment if it is a go:generate directive of the form
+// "go run [flags] pkg[@version] [args]", and the empty string
+// otherwise.
+func goRunPackage(comment string) string {
+	line, ok := strings.CutPrefix(comment, "//go:generate ")
+	if !ok {
+		return ""
+	}
+	args := strings.Fields(line)
+	if len(args) < 3 || args[0] != "go" || args[1] != "run" {
+		return ""
+	}
+	for _, arg := range args[2:] {
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		pkg, _, _ := strings.Cut(arg, "@")
+		if strings.HasSuffix(pkg, ".go") || strings.HasPrefix(pkg, ".") || !strings.Contains(pkg, ".") {
+			return ""
+		}
+		return pkg
+	}
+	return ""
+}
+
+// generateToolModules returns the modules providing the given tool
+// packages, as resolved from the module in dir. Tools that cannot be
+// resolved, such as those run at a version that the module does not
+// require, are logged and otherwise ignored.
+func generateToolModules(dir string, tools map[string]struct{}) map[string]struct{} {
+	mods := make(map[string]struct{})
+	if len(tools) == 0 {
+		return mods
+	}
+	cfg := newConfig(dir, false)
+	cfg.Mode = packages.NeedName | packages.NeedModule
+	pkgs, err := loadPackages("load-generate-tools", cfg, sortedKeys(tools))
+	if err != nil {
+		log.Printf("cannot load go:generate tools: %v", err)
+		return mods
+	}
+	for _, p := range pkgs {
+		switch {
+		case len(p.Errors) > 0 || p.Module == nil:
+			log.Printf("cannot find module providing go:generate tool %s", p.PkgPath)
+		case !p.Module.Main:
+			mods[p.Module.Path] = struct{}{}
+		}
+	}
+	return mods
+}
+
+// addGenerateToolEdges adds a labeled edge from the main module to each
+// of the given modules providing go:generate tools, adding nodes for any
+// that are not already in the graph, and highlights them.
+func (g *graph) addGenerateToolEdges(mods map[string]struct{}) {
+	for _, m := range sortedKeys(mods) {
+		g.nodes[m] = struct{}{}
+		g.extraEdges = append(g.extraEdges, labeledEdge{edge{g.mainMod, m}, "generate"})
+	}
+	g.overlays = append(g.overlays, classOverlay{
+		name:  "generateTool",
+		style: generateToolStyle,
+		nodes: mods,
+	})
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGoRunPackage(t *testing.T) {
+	for _, test := range []struct {
+		comment string
+		want    string
+	}{
+		{"//go:generate go run example.com/tool", "example.com/tool"},
+		{"//go:generate go run -mod=mod example.com/tool@v1.2.0 -out x.go", "example.com/tool"},
+		{"//go:generate go run ./internal/gen", ""},
+		{"//go:generate go run gen.go", ""},
+		{"//go:generate go run fmt", ""},
+		{"//go:generate stringer -type T", ""},
+		{"// go:generate go run example.com/tool", ""},
+		{"//go:generate go run", ""},
+	} {
+		if got := goRunPackage(test.comment); got != test.want {
+			t.Errorf("goRunPackage(%q) = %q, want %q", test.comment, got, test.want)
+		}
+	}
+}
+
+func TestGenerateTools(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "go.mod"), "module example.com/m\n")
+	writeFile(t, filepath.Join(dir, "m.go"), "package m\n\n//go:generate go run example.com/one -v\n//go:generate go run ./gen\n")
+	writeFile(t, filepath.Join(dir, "sub", "sub.go"), "package sub\n\n//go:generate go run example.com/two@latest\n")
+	writeFile(t, filepath.Join(dir, "bad.go"), "package m\n\nfunc {\n")
+	for _, skipped := range []string{"testdata", "vendor", "_old", ".hidden", "nested"} {
+		writeFile(t, filepath.Join(dir, skipped, "x.go"), "package x\n\n//go:generate go run example.com/"+skipped+"\n")
+	}
+	writeFile(t, filepath.Join(dir, "nested", "go.mod"), "module example.com/nested\n")
+	tools, err := generateTools(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sortedKeys(tools), []string{"example.com/one", "example.com/two"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got tools %q, want %q", got, want)
+	}
+}
+
+func TestScanGenerate(t *testing.T) {
+	root := t.TempDir()
+	copyDir(t, root, fixture(t, "fx"))
+	dir := filepath.Join(root, "main")
+	writeFile(t, filepath.Join(dir, "gen.go"), strings.Join([]string{
+		"package main",
+		"",
+		"//go:generate go run example.com/d",
+		"//go:generate go run example.com/main/sub",
+		"//go:generate go run example.com/unknown",
+		"",
+	}, "\n"))
+	r := runMain(t, dir, nil, "-scan-generate")
+	if r.failed {
+		t.Fatalf("-scan-generate failed:\n%s", r.stderr)
+	}
+	// example.com/main is N7 and example.com/d is N3. The package in the
+	// main module itself gets no edge, and the unknown tool is just logged.
+	if got := strings.Count(r.stdout, "-.->"); got != 1 || !strings.Contains(r.stdout, `N7 -.->|"generate"| N3`+"\n") {
+		t.Errorf("output does not have just a generate edge from the main module to example.com/d:\n%s", r.stdout)
+	}
+	if !strings.Contains(r.stdout, "class N3 generateTool;") {
+		t.Errorf("example.com/d is not highlighted as a tool:\n%s", r.stdout)
+	}
+	if !strings.Contains(r.stderr, "example.com/unknown") {
+		t.Errorf("unknown tool is not logged:\n%s", r.stderr)
+	}
+}
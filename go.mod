@@ -2,9 +2,9 @@ module github.com/rogpeppe/gotestdeps
 
 go 1.25
 
-require golang.org/x/tools v0.33.0
-
 require (
-	golang.org/x/mod v0.24.0 // indirect
-	golang.org/x/sync v0.14.0 // indirect
+	golang.org/x/mod v0.24.0
+	golang.org/x/tools v0.33.0
 )
+
+require golang.org/x/sync v0.14.0 // indirect
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+)
+
+// writeGo writes g as a Go source file in the package named by
+// -go-package, declaring a Graph variable that holds the nodes
+// and edges so that a snapshot of the graph can be compiled into
+// another program.
+func writeGo(out io.Writer, g *graph) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by gotestdeps; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", *flagGoPackage)
+	fmt.Fprintf(&buf, "// Graph holds the module dependency graph of %s.\n", g.mainMod)
+	fmt.Fprintf(&buf, "var Graph = struct {\n")
+	fmt.Fprintf(&buf, "Main string\n")
+	fmt.Fprintf(&buf, "Nodes []struct{ Path, Class, Version string; TestOnly bool }\n")
+	fmt.Fprintf(&buf, "Edges []struct{ From, To string }\n")
+	fmt.Fprintf(&buf, "}{\n")
+	fmt.Fprintf(&buf, "Main: %q,\n", g.mainMod)
+	fmt.Fprintf(&buf, "Nodes: []struct{ Path, Class, Version string; TestOnly bool }{\n")
+	cg := canonicalize(g)
+	for _, n := range cg.nodes {
+		fmt.Fprintf(&buf, "{%q, %q, %q, %v},\n", n.path, n.class, n.version, n.testOnly)
+	}
+	fmt.Fprintf(&buf, "},\n")
+	fmt.Fprintf(&buf, "Edges: []struct{ From, To string }{\n")
+	for _, e := range cg.edges {
+		fmt.Fprintf(&buf, "{%q, %q},\n", e.from, e.to)
+	}
+	fmt.Fprintf(&buf, "},\n")
+	fmt.Fprintf(&buf, "}\n")
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("cannot format Go source: %v", err)
+	}
+	out.Write(src)
+}
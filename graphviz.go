@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// writeGraphviz writes g in the Graphviz DOT language, filling
+// each node with the color of its class. With -dot-records,
+// each node is drawn as a record showing its path, version
+// and class in separate fields.
+func writeGraphviz(out io.Writer, g *graph) {
+	fmt.Fprintf(out, "digraph G {\n")
+	fmt.Fprintf(out, "\trankdir=LR;\n")
+	fmt.Fprintf(out, "\tlayout=%s;\n", *flagDotEngine)
+	if *flagDotEngine != "dot" {
+		// Force-directed layouts otherwise tend to
+		// draw nodes on top of each other.
+		fmt.Fprintf(out, "\toverlap=false;\n")
+		fmt.Fprintf(out, "\tsplines=true;\n")
+	}
+	if *flagRankSep != 0 {
+		fmt.Fprintf(out, "\tranksep=%s;\n", dotQuote(strconv.FormatFloat(*flagRankSep, 'g', -1, 64)))
+	}
+	if *flagNodeSep != 0 {
+		fmt.Fprintf(out, "\tnodesep=%s;\n", dotQuote(strconv.FormatFloat(*flagNodeSep, 'g', -1, 64)))
+	}
+	fmt.Fprintf(out, "\tnode [shape=rectangle style=filled];\n")
+	for _, n := range sortedKeys(g.nodes) {
+		class, color, _ := g.classify(n)
+		label := dotQuote(g.label(n))
+		if *flagDotRecords {
+			fields := []string{g.label(n), g.versions[n], class}
+			for i, f := range fields {
+				fields[i] = recordEscaper.Replace(f)
+			}
+			label = dotQuote("{" + strings.Join(fields, "|") + "}")
+			fmt.Fprintf(out, "\t%s [shape=record label=%s fillcolor=%s];\n", dotQuote(n), label, dotQuote(color))
+			continue
+		}
+		fmt.Fprintf(out, "\t%s [label=%s fillcolor=%s];\n", dotQuote(n), label, dotQuote(color))
+	}
+	for _, from := range sortedKeys(g.edges) {
+		for _, to := range g.successors(from) {
+			fmt.Fprintf(out, "\t%s -> %s;\n", dotQuote(from), dotQuote(to))
+		}
+	}
+	fmt.Fprintf(out, "}\n")
+}
+
+// dotQuote returns s as a quoted DOT string. Unlike Go, DOT
+// treats only the sequence \" as an escape within a string.
+func dotQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// recordEscaper escapes the characters that are special
+// inside a field of a Graphviz record label.
+var recordEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"{", `\{`,
+	"}", `\}`,
+	"|", `\|`,
+	"<", `\<`,
+	">", `\>`,
+	" ", `\ `,
+)
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDotSpacing(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	out := mustRun(t, dir, "-format", "dot")
+	if strings.Contains(out, "ranksep") || strings.Contains(out, "nodesep") {
+		t.Errorf("default output sets the spacing:\n%s", out)
+	}
+	out = mustRun(t, dir, "-format", "dot", "-ranksep", "1.5", "-nodesep", "0.25")
+	if want := "\tlayout=dot;\n\tranksep=\"1.5\";\n\tnodesep=\"0.25\";\n"; !strings.Contains(out, want) {
+		t.Errorf("output does not contain %q:\n%s", want, out)
+	}
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-format", "dot", "-ranksep", "-1"}, "-ranksep and -nodesep must not be negative"},
+		{[]string{"-nodesep", "1"}, "-ranksep and -nodesep apply only to the dot format"},
+	} {
+		r := runMain(t, dir, nil, test.args...)
+		if !r.failed || !strings.Contains(r.stderr, test.want) {
+			t.Errorf("%q did not fail with %q:\n%s", test.args, test.want, r.stderr)
+		}
+	}
+}
+
+func TestDotRecords(t *testing.T) {
+	checkGolden(t, "fx-dot-records.dot", mustRun(t, fixture(t, "fx/main"), "-format", "dot", "-dot-records", "-package-counts"))
+}
+
+func TestRecordEscaper(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want string
+	}{
+		{"example.com/a", "example.com/a"},
+		{"a [1p]", `a\ [1p]`},
+		{`{a|b}`, `\{a\|b\}`},
+		{`<x>\`, `\<x\>\\`},
+	} {
+		if got := recordEscaper.Replace(test.s); got != test.want {
+			t.Errorf("recordEscaper.Replace(%q) = %q, want %q", test.s, got, test.want)
+		}
+	}
+}
+
+func TestDotEngine(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	out := mustRun(t, dir, "-format", "dot")
+	if !strings.Contains(out, "\tlayout=dot;\n") || strings.Contains(out, "overlap") {
+		t.Errorf("default output does not just select the dot layout:\n%s", out)
+	}
+	out = mustRun(t, dir, "-format", "dot", "-dot-engine", "neato")
+	if want := "\tlayout=neato;\n\toverlap=false;\n\tsplines=true;\n"; !strings.Contains(out, want) {
+		t.Errorf("output does not contain %q:\n%s", want, out)
+	}
+	r := runMain(t, dir, nil, "-format", "dot", "-dot-engine", "circo")
+	if want := `invalid -dot-engine value "circo" (want dot, neato, fdp or sfdp)`; !r.failed || !strings.Contains(r.stderr, want) {
+		t.Errorf("-dot-engine circo did not fail with %q:\n%s", want, r.stderr)
+	}
+}
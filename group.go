@@ -0,0 +1,76 @@
+package main
+
+import (
+	"maps"
+	"strings"
+)
+
+// groupOf returns the name of the group that the given module path
+// belongs to under the given -group-by mode: its first path element
+// for "host", or its first two for "org", such as github.com/myorg.
+func groupOf(path, by string) string {
+	n := 2
+	if by == "host" {
+		n = 1
+	}
+	elems := strings.SplitN(path, "/", n+1)
+	return strings.Join(elems[:min(n, len(elems))], "/")
+}
+
+// groups returns the nodes of g divided into groups by the given mode.
+func (g *graph) groups(by string) map[string]map[string]struct{} {
+	groups := make(map[string]map[string]struct{})
+	for n := range g.nodes {
+		var name string
+		if by == "license" {
+			name = licenseFamily(g.dirs[n])
+		} else {
+			name = groupOf(n, by)
+		}
+		if groups[name] == nil {
+			groups[name] = make(map[string]struct{})
+		}
+		groups[name][n] = struct{}{}
+	}
+	return groups
+}
+
+// groupGraph returns a copy of g holding just the given members
+// and the edges to and from them, along with the modules at the
+// other ends of those edges. The members are drawn together as
+// a cluster with the given title.
+func (g *graph) groupGraph(title string, members map[string]struct{}) *graph {
+	sub := *g
+	sub.nodes = maps.Clone(members)
+	sub.edges = make(map[string]map[string]struct{})
+	for from, tos := range g.edges {
+		_, fromIn := members[from]
+		for to := range tos {
+			if _, toIn := members[to]; !fromIn && !toIn {
+				continue
+			}
+			if sub.edges[from] == nil {
+				sub.edges[from] = make(map[string]struct{})
+			}
+			sub.edges[from][to] = struct{}{}
+			sub.nodes[from] = struct{}{}
+			sub.nodes[to] = struct{}{}
+		}
+	}
+	sub.clusters = []cluster{{title: title, nodes: members}}
+	return &sub
+}
+
+// fileNameEscaper replaces the characters of a module path or
+// group name that are awkward or invalid in file names.
+var fileNameEscaper = strings.NewReplacer(
+	"/", "_",
+	`\`, "_",
+	":", "_",
+	"*", "_",
+	"?", "_",
+	`"`, "_",
+	"<", "_",
+	">", "_",
+	"|", "_",
+)
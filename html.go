@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"log"
+)
+
+// htmlTemplate is a minimal page that renders a mermaid diagram
+// using mermaid loaded from a CDN. Rendering at the diagram's natural
+// size rather than fitting it to the window allows large graphs to be
+// panned by scrolling and zoomed with the browser's own controls.
+var htmlTemplate = template.Must(template.New("").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} dependencies</title>
+<style>
+body { margin: 1em; font-family: sans-serif; }
+pre.mermaid { overflow: auto; }
+</style>
+</head>
+<body>
+<pre class="mermaid">
+{{.Source}}</pre>
+<script type="module">
+import mermaid from "https://cdn.jsdelivr.net/npm/mermaid@11/dist/mermaid.esm.min.mjs";
+mermaid.initialize({
+	startOnLoad: true,
+	flowchart: {useMaxWidth: false},
+	maxTextSize: 10000000,
+	maxEdges: 100000,
+});
+</script>
+</body>
+</html>
+`))
+
+// writeHTML writes g as a self-contained HTML page that renders
+// the mermaid diagram when opened in a browser.
+func writeHTML(out io.Writer, g *graph) {
+	var src bytes.Buffer
+	writeMermaid(&src, g)
+	err := htmlTemplate.Execute(out, struct {
+		Title  string
+		Source string
+	}{
+		Title:  g.mainMod,
+		Source: src.String(),
+	})
+	if err != nil {
+		log.Fatalf("cannot write HTML: %v", err)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+)
+
+// jsonSchemaVersion identifies the structure of the JSON output.
+// It must be incremented whenever that structure changes,
+// and jsonSchemaText updated to match.
+const jsonSchemaVersion = 2
+
+// jsonSchemaText is a JSON Schema describing the output of writeJSON.
+// It must be kept in step with jsonGraph, jsonNode and jsonEdge;
+// its $id is derived from jsonSchemaVersion.
+const jsonSchemaText = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"$id": "https://github.com/rogpeppe/gotestdeps/schema/v%d.json",
+	"title": "gotestdeps graph",
+	"type": "object",
+	"required": ["schema", "main", "nodes", "edges"],
+	"properties": {
+		"schema": {
+			"description": "The version of this schema that the output conforms to.",
+			"const": %d
+		},
+		"main": {
+			"description": "The path of the main module.",
+			"type": "string"
+		},
+		"nodes": {
+			"description": "The modules in the graph, sorted by path.",
+			"type": "array",
+			"items": {
+				"type": "object",
+				"required": ["path", "class", "testOnly"],
+				"properties": {
+					"path": {"type": "string"},
+					"class": {
+						"description": "The class of the module: one of mainModule, testOnlyDep and regularDep. Classes used only for drawing, such as those of -colors-file, are not recorded.",
+						"type": "string"
+					},
+					"version": {
+						"description": "The selected version of the module, omitted when unknown.",
+						"type": "string"
+					},
+					"testOnly": {
+						"description": "Whether the module is needed only by tests.",
+						"type": "boolean"
+					}
+				},
+				"additionalProperties": false
+			}
+		},
+		"edges": {
+			"description": "The dependencies between modules, sorted by source and then target.",
+			"type": "array",
+			"items": {
+				"type": "object",
+				"required": ["from", "to", "test"],
+				"properties": {
+					"from": {"type": "string"},
+					"to": {"type": "string"},
+					"test": {
+						"description": "Whether the edge is found only when tests are loaded.",
+						"type": "boolean"
+					},
+					"packageEdges": {
+						"description": "The number of distinct package imports that the edge stands for; present only at module granularity.",
+						"type": "integer",
+						"minimum": 1
+					}
+				},
+				"additionalProperties": false
+			}
+		}
+	},
+	"additionalProperties": false
+}
+`
+
+// writeJSONSchema writes the JSON Schema of the -format json output.
+func writeJSONSchema(out io.Writer) {
+	fmt.Fprintf(out, jsonSchemaText, jsonSchemaVersion, jsonSchemaVersion)
+}
+
+// jsonGraph is the JSON form of a graph. All its slices
+// are sorted so that the output is deterministic.
+type jsonGraph struct {
+	Schema int        `json:"schema"`
+	Main   string     `json:"main"`
+	Nodes  []jsonNode `json:"nodes"`
+	Edges  []jsonEdge `json:"edges"`
+}
+
+type jsonNode struct {
+	Path     string `json:"path"`
+	Class    string `json:"class"`
+	Version  string `json:"version,omitempty"`
+	TestOnly bool   `json:"testOnly"`
+}
+
+type jsonEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Test reports whether the edge is found only when tests are loaded.
+	Test bool `json:"test"`
+	// PackageEdges holds the number of distinct importing and
+	// imported package pairs that make up a module edge.
+	PackageEdges int `json:"packageEdges,omitempty"`
+}
+
+// writeJSON writes g as JSON, with nodes sorted by path
+// and edges sorted by source and then target.
+func writeJSON(out io.Writer, g *graph) {
+	cg := canonicalize(g)
+	jg := jsonGraph{
+		Schema: jsonSchemaVersion,
+		Main:   cg.main,
+		Nodes:  []jsonNode{},
+		Edges:  []jsonEdge{},
+	}
+	for _, n := range cg.nodes {
+		jg.Nodes = append(jg.Nodes, jsonNode{
+			Path:     n.path,
+			Class:    n.class,
+			Version:  n.version,
+			TestOnly: n.testOnly,
+		})
+	}
+	for _, ce := range cg.edges {
+		e := jsonEdge{From: ce.from, To: ce.to, Test: ce.test}
+		if *flagGranularity == "module" {
+			e.PackageEdges = ce.count
+		}
+		jg.Edges = append(jg.Edges, e)
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(jg); err != nil {
+		log.Fatalf("cannot write JSON: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSONPackageEdges(t *testing.T) {
+	var jg jsonGraph
+	if err := json.Unmarshal([]byte(mustRun(t, fixture(t, "fx/main"), "-format", "json")), &jg); err != nil {
+		t.Fatal(err)
+	}
+	if jg.Schema != 2 {
+		t.Errorf("got schema %d, want 2 as packageEdges changed the format", jg.Schema)
+	}
+	got := make(map[edge]int)
+	for _, e := range jg.Edges {
+		got[edge{e.From, e.To}] = e.PackageEdges
+	}
+	// Each count is the number of distinct pairs of an importing
+	// package in the first module and an imported package in the
+	// second. The test variant of a package is not a package of its
+	// own, so example.com/main and its test together import
+	// example.com/a just once, while example.com/main and
+	// example.com/main/sub import example.com/b twice.
+	want := map[edge]int{
+		{"example.com/main", "example.com/a"}: 1,
+		{"example.com/main", "example.com/b"}: 2,
+		{"example.com/main", "example.com/t"}: 1,
+		{"example.com/a", "example.com/c"}:    1,
+		{"example.com/a", "example.com/f"}:    1,
+		{"example.com/b", "example.com/c"}:    1,
+		{"example.com/b", "example.com/d"}:    1,
+		{"example.com/c", "example.com/x"}:    1,
+		{"example.com/f", "example.com/g"}:    1,
+		{"example.com/t", "example.com/e"}:    1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got package edge counts %v, want %v", got, want)
+	}
+}
+
+func TestJSONTestEdges(t *testing.T) {
+	var jg jsonGraph
+	if err := json.Unmarshal([]byte(mustRun(t, fixture(t, "fx/main"), "-format", "json")), &jg); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[edge]bool)
+	for _, e := range jg.Edges {
+		got[edge{e.From, e.To}] = e.Test
+	}
+	// example.com/t is a production module because "all" includes
+	// the main module's tests, but the edge to it is found only
+	// when tests are loaded.
+	want := map[edge]bool{
+		{"example.com/main", "example.com/a"}: false,
+		{"example.com/main", "example.com/b"}: false,
+		{"example.com/main", "example.com/t"}: true,
+		{"example.com/a", "example.com/c"}:    false,
+		{"example.com/a", "example.com/f"}:    true,
+		{"example.com/b", "example.com/c"}:    false,
+		{"example.com/b", "example.com/d"}:    false,
+		{"example.com/c", "example.com/x"}:    true,
+		{"example.com/f", "example.com/g"}:    true,
+		{"example.com/t", "example.com/e"}:    false,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got test edges %v, want %v", got, want)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	var schema struct {
+		ID         string `json:"$id"`
+		Properties struct {
+			Schema struct {
+				Const int `json:"const"`
+			} `json:"schema"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal([]byte(mustRun(t, fixture(t, "fx/main"), "-json-schema")), &schema); err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://github.com/rogpeppe/gotestdeps/schema/v2.json"; schema.ID != want {
+		t.Errorf("got $id %q, want %q", schema.ID, want)
+	}
+	if got := schema.Properties.Schema.Const; got != jsonSchemaVersion {
+		t.Errorf("schema requires version %d, want %d", got, jsonSchemaVersion)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// layerViolationStyle is the link style used for edges
+// that depend upwards across architectural layers.
+const layerViolationStyle = "stroke:#d00,stroke-width:2px"
+
+// layerRule assigns the modules matching a pattern to a layer.
+type layerRule struct {
+	pattern *regexp.Regexp
+	layer   string
+}
+
+// layerRules implements flag.Value for the repeatable -layer flag.
+// Layers are ranked by the order in which their names first appear,
+// highest first; a module may only depend on modules in its own layer
+// or in lower ones.
+type layerRules []layerRule
+
+func (r *layerRules) String() string {
+	return strings.Join(r.values(), " ")
+}
+
+func (r *layerRules) values() []string {
+	var rules []string
+	for _, rule := range *r {
+		rules = append(rules, rule.pattern.String()+"="+rule.layer)
+	}
+	return rules
+}
+
+func (r *layerRules) Set(s string) error {
+	i := strings.LastIndex(s, "=")
+	if i < 0 {
+		return fmt.Errorf("layer rule %q is not of the form REGEXP=NAME", s)
+	}
+	pattern, err := regexp.Compile(s[:i])
+	if err != nil {
+		return err
+	}
+	*r = append(*r, layerRule{pattern, s[i+1:]})
+	return nil
+}
+
+// rank returns the rank of the layer that the given module
+// belongs to, where 0 is the highest layer. It returns -1 if
+// the module matches none of the rules.
+func (r layerRules) rank(module string) int {
+	ranks := make(map[string]int)
+	for _, rule := range r {
+		if _, ok := ranks[rule.layer]; !ok {
+			ranks[rule.layer] = len(ranks)
+		}
+		if rule.pattern.MatchString(module) {
+			return ranks[rule.layer]
+		}
+	}
+	return -1
+}
+
+// checkLayers logs each edge in g that depends on a module in a higher
+// layer than its own, highlighting it in the graph, and returns the
+// number of such edges.
+func checkLayers(g *graph) int {
+	if len(flagLayers) == 0 {
+		return 0
+	}
+	violations := 0
+	for _, from := range sortedKeys(g.edges) {
+		fromRank := flagLayers.rank(from)
+		if fromRank < 0 {
+			continue
+		}
+		for _, to := range sortedKeys(g.edges[from]) {
+			toRank := flagLayers.rank(to)
+			if toRank < 0 || toRank >= fromRank {
+				continue
+			}
+			log.Printf("layer violation: %s depends on %s", from, to)
+			g.edgeStyles[edge{from, to}] = layerViolationStyle
+			violations++
+		}
+	}
+	return violations
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLayerRank(t *testing.T) {
+	var rules layerRules
+	for _, s := range []string{"^example\\.com/app=top", "^example\\.com/lib=bottom", "^example\\.com/cmd=top"} {
+		if err := rules.Set(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for module, want := range map[string]int{
+		"example.com/app":   0,
+		"example.com/cmd/x": 0,
+		"example.com/lib":   1,
+		"example.com/other": -1,
+	} {
+		if got := rules.rank(module); got != want {
+			t.Errorf("rank(%q) = %d, want %d", module, got, want)
+		}
+	}
+	if err := rules.Set("no-equals"); err == nil {
+		t.Errorf("Set accepted a rule without a layer name")
+	}
+}
+
+func TestEnforceLayers(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	// Putting example.com/c above example.com/a makes the
+	// dependency of a on c go upwards, while that of b on d
+	// stays within the bottom layer.
+	layers := []string{"-layer", "^example\\.com/c$=top", "-layer", "^example\\.com/[abd]$=bottom"}
+	r := runMain(t, dir, nil, layers...)
+	if r.failed {
+		t.Fatalf("layer violations failed without -enforce-layers:\n%s", r.stderr)
+	}
+	if got := strings.Count(r.stderr, "layer violation:"); got != 2 {
+		t.Errorf("got %d layer violations, want 2 (a and b on c):\n%s", got, r.stderr)
+	}
+	if !strings.Contains(r.stderr, "layer violation: example.com/a depends on example.com/c") {
+		t.Errorf("violation of a on c not reported:\n%s", r.stderr)
+	}
+	if !strings.Contains(r.stdout, "stroke:#d00,stroke-width:2px") {
+		t.Errorf("violating edges not highlighted:\n%s", r.stdout)
+	}
+	r = runMain(t, dir, nil, append(layers, "-enforce-layers")...)
+	if !r.failed {
+		t.Errorf("layer violations did not fail with -enforce-layers")
+	}
+	r = runMain(t, dir, nil, "-layer", "^example\\.com/[abcd]$=one", "-enforce-layers")
+	if r.failed {
+		t.Errorf("-enforce-layers failed with no violations:\n%s", r.stderr)
+	}
+}
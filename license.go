@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// licenseMarkers maps distinctive text found in license files
+// to the family of the license. They are tried in order,
+// so that, for example, the GPL is recognized before the
+// permission grant it shares with more liberal licenses.
+var licenseMarkers = []struct {
+	text   string
+	family string
+}{
+	{"GNU AFFERO GENERAL PUBLIC LICENSE", "GPL"},
+	{"GNU LESSER GENERAL PUBLIC LICENSE", "GPL"},
+	{"GNU GENERAL PUBLIC LICENSE", "GPL"},
+	{"Apache License", "Apache-2.0"},
+	{"Mozilla Public License", "MPL-2.0"},
+	{"Permission is hereby granted, free of charge", "MIT"},
+	{"Redistribution and use in source and binary forms", "BSD"},
+}
+
+// licenseFamily returns the family of the license found in the top level
+// of the module directory dir, or "Unknown" if there is no recognizable
+// license file. This is a heuristic based on the license text rather than
+// a full license identification.
+func licenseFamily(dir string) string {
+	if dir == "" {
+		return "Unknown"
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "Unknown"
+	}
+	for _, e := range entries {
+		name := strings.ToUpper(e.Name())
+		if e.IsDir() || !strings.HasPrefix(name, "LICENSE") && !strings.HasPrefix(name, "LICENCE") && !strings.HasPrefix(name, "COPYING") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		for _, m := range licenseMarkers {
+			if bytes.Contains(data, []byte(m.text)) {
+				return m.family
+			}
+		}
+	}
+	return "Unknown"
+}
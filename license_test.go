@@ -0,0 +1,59 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLicenseFamily(t *testing.T) {
+	root := t.TempDir()
+	for _, test := range []struct {
+		file string
+		text string
+		want string
+	}{
+		{"LICENSE", "Permission is hereby granted, free of charge, to any person", "MIT"},
+		{"LICENSE.md", "Apache License\nVersion 2.0, January 2004\n", "Apache-2.0"},
+		{"COPYING", "GNU LESSER GENERAL PUBLIC LICENSE\nPermission is hereby granted, free of charge", "GPL"},
+		{"licence", "Redistribution and use in source and binary forms", "BSD"},
+		{"LICENSE", "All rights reserved.", "Unknown"},
+		{"README", "Permission is hereby granted, free of charge", "Unknown"},
+	} {
+		dir := filepath.Join(root, test.want, test.file)
+		writeFile(t, filepath.Join(dir, test.file), test.text)
+		if got := licenseFamily(dir); got != test.want {
+			t.Errorf("%s holding %q: got license family %q, want %q", test.file, test.text, got, test.want)
+		}
+	}
+	for _, dir := range []string{"", filepath.Join(root, "missing")} {
+		if got := licenseFamily(dir); got != "Unknown" {
+			t.Errorf("licenseFamily(%q) = %q, want Unknown", dir, got)
+		}
+	}
+}
+
+func TestGroupByLicense(t *testing.T) {
+	root := t.TempDir()
+	copyDir(t, root, fixture(t, "fx"))
+	writeFile(t, filepath.Join(root, "a", "LICENSE"), "Permission is hereby granted, free of charge, to any person\n")
+	writeFile(t, filepath.Join(root, "b", "LICENSE.txt"), "Apache License\nVersion 2.0, January 2004\n")
+	dir := filepath.Join(root, "main")
+	out := mustRun(t, dir, "-group-by", "license")
+	want := strings.Join([]string{
+		`    subgraph C0["Apache-2.0"]`,
+		`        N1["example.com/b"]`,
+		`    end`,
+		`    subgraph C1["MIT"]`,
+		`        N0["example.com/a"]`,
+		`    end`,
+		`    subgraph C2["Unknown"]`,
+		`        N2["example.com/c"]`,
+	}, "\n")
+	if !strings.Contains(out, want) {
+		t.Errorf("output does not contain:\n%s\ngot:\n%s", want, out)
+	}
+	if got := mustRun(t, dir, "-group-by-license"); got != out {
+		t.Errorf("-group-by-license differs from -group-by license:\n%s", got)
+	}
+}
@@ -1,21 +1,39 @@
-// Command gomodviz prints a GraphViz “dot” graph of the current module’s
-// dependencies, colouring modules required only by tests in red.
+// Command gotestdeps prints a graph of the current module’s dependencies,
+// highlighting the modules that are required only by tests.
 //
-//	go run . > deps.mmd
+//	gotestdeps > deps.mmd
+//
+// By default the graph is a mermaid flowchart. The -format flag selects
+// other outputs, including Graphviz dot, JSON, a text tree and HTML, and
+// many other flags filter, annotate or check the graph.
 //
 // Requires: go1.22+ and golang.org/x/tools/go/packages.
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"container/list"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"go/token"
 	"io"
 	"log"
+	"maps"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
 	"golang.org/x/tools/go/packages"
 )
 
@@ -25,61 +43,1557 @@ const (
 	mainColor    = "#ddffdd"
 )
 
+var (
+	flagCompact          = flag.Bool("compact", false, "hide single-parent leaf modules, annotating their parent with a count")
+	flagRoot             = flag.String("root", "", "show only the modules reachable from `module`, treating it as the main module")
+	flagCache            = flag.String("cache", "", "cache load results in `dir` and reuse them while the module is unchanged")
+	flagSortEdges        = flag.String("sort-edges", "name", "order of each module's outgoing edges: `name` or count (most package imports first)")
+	flagFormat           = flag.String("format", "mermaid", "comma-separated output `formats`: mermaid, tree, json, html, svg, canonical, go, markdown, make, dot, mermaid-class, cypher, excalidraw, matrix or ndjson-events; more than one requires -o")
+	flagASCII            = flag.Bool("ascii", false, "draw the tree format with plain ASCII rather than Unicode box-drawing characters")
+	flagPorcelain        = flag.Bool("porcelain", false, "write the stable line-oriented format intended for scripts")
+	flagDeepTests        = flag.Bool("deep-tests", false, "also load every package of each dependency module with its tests (slow)")
+	flagNoEdgesToMain    = flag.Bool("no-edges-to-main", false, "omit edges that point back into the main module")
+	flagMainColor        = flag.String("color-main", mainColor, "fill `color` for the main module")
+	flagTestColor        = flag.String("color-test", testColor, "fill `color` for test-only modules")
+	flagRegularColor     = flag.String("color-regular", nonTestColor, "fill `color` for other modules")
+	flagMinify           = flag.Bool("minify", false, "omit indentation from mermaid output")
+	flagStrict           = flag.Bool("strict", false, "treat warnings about the dependency graph as errors")
+	flagMainTestsAsProd  = flag.Bool("count-main-tests-as-prod", false, "do not count modules needed only by the main module's own tests as test-only (the all pattern already includes them)")
+	flagOutput           = flag.String("o", "", "write output to `file` instead of standard output")
+	flagCRLF             = flag.Bool("crlf", false, "end output lines with CRLF rather than LF")
+	flagPackageCounts    = flag.Bool("package-counts", false, "annotate each module with the number of its packages that are used")
+	flagIgnoreExamples   = flag.Bool("ignore-examples", false, "ignore tests whose test files contain only Example functions")
+	flagEnforceLayers    = flag.Bool("enforce-layers", false, "exit with a non-zero status if any module depends on a higher layer")
+	flagBaseline         = flag.String("baseline", "", "highlight modules not listed in `file`, such as the output of go list -m all")
+	flagPruneTestLeaves  = flag.Bool("prune-test-leaves", false, "hide single-parent test-only leaf modules, annotating their parent with a count")
+	flagGOOS             = flag.String("goos", "", "load packages as for the given target `os`")
+	flagGOARCH           = flag.String("goarch", "", "load packages as for the given target `arch`")
+	flagShowReplaceEdges = flag.Bool("show-replace-edges", false, "show replacements as separate nodes linked from the modules they replace")
+	flagVersionBelow     = flag.String("version-below", "", "highlight modules whose selected version is below `semver`")
+	flagTrace            = flag.Bool("trace", false, "log load configuration and the time taken by each phase")
+	flagClipboard        = flag.Bool("clipboard", false, "copy the output to the system clipboard instead of writing it to standard output")
+	flagExplainTestOnly  = flag.String("explain-testonly", "", "print the chain of imports from test code that pulls in the test-only `module`")
+	flagProdOnly         = flag.Bool("prod-only", false, "show only the graph found without loading tests, omitting test-only modules")
+	flagDiffFocus        = flag.String("diff-focus", "", "show only modules added or changed relative to the module checked out in `dir`, with their neighbors")
+	flagVersions         = flag.Bool("versions", false, "annotate each module with its selected version")
+	flagShortVersions    = flag.Bool("short-versions", false, "like -versions but abbreviate pseudo-versions and drop +incompatible")
+	flagRenderer         = flag.String("renderer", "", "mermaid flowchart layout `renderer`: dagre or elk, which lays out large graphs better")
+	flagRequireFile      = flag.String("require-file", "", "show only the modules required directly by the go.mod `file`, without loading any packages; test-only modules are not identified")
+	flagDeprecations     = flag.Bool("deprecations", false, "highlight modules marked as deprecated in their go.mod files")
+	flagBundleCommon     = flag.Int("bundle-common", 0, "draw edges faintly when they lead to a module with at least `n` incoming edges")
+	flagEdgesOnly        = flag.Bool("edges-only", false, "write only the mermaid edge lines, printing the module for each node id to standard error")
+	flagSample           = flag.Float64("sample", 1, "keep each edge with probability `p`, except those needed to keep every module reachable; lossy, but the same edges are kept on every run")
+	flagDeepTestReport   = flag.Bool("deep-test-report", false, "list test-only modules by their depth within the test-only part of the graph, deepest first, instead of the graph")
+	flagPatternsFrom     = flag.String("patterns-from", "", "load the newline-separated package patterns in `file` (- for standard input) instead of all")
+	flagSizes            = flag.Bool("sizes", false, "annotate each module with the size of its source on disk")
+	flagGroupTestOnly    = flag.Bool("group-test-only", false, "draw the test-only modules together in a separate box in mermaid output")
+	flagTestOnlyMode     = flag.String("test-only-mode", "transitive", "which modules needed only by tests count as test-only: `transitive` (all of them) or direct (only those imported by test files)")
+	flagShowPseudo       = flag.Bool("show-pseudo", false, "highlight and list modules whose selected version is a pseudo-version")
+	flagGoPackage        = flag.String("go-package", "deps", "package `name` used by the go format")
+	flagGranularity      = flag.String("granularity", "module", "draw a node for each `module` or for each package")
+	flagIncludeStdlib    = flag.Bool("include-stdlib", false, "include standard library packages; requires -granularity package")
+	flagCollapseStd      = flag.Bool("collapse-std", false, "with -include-stdlib, merge all standard library packages into a single std node")
+	flagTools            = flag.Bool("tools", false, "highlight modules providing tools listed in go.mod, with a dotted edge from the main module")
+	flagRetractions      = flag.Bool("retractions", false, "highlight modules whose selected version is retracted by their latest go.mod files")
+	flagInlineStyles     = flag.Bool("inline-styles", false, "style each mermaid node directly rather than with classDef, for renderers without class support")
+	flagMaxLabelLen      = flag.Int("max-label-len", 0, "shorten module paths in labels to at most `n` characters, showing the full path as a mermaid tooltip")
+	flagPruneSubtree     = flag.String("prune-subtree", "", "omit `module` and any modules that only it makes reachable from the main module")
+	flagReachableFrom    = flag.String("reachable-from", "", "show only the modules reachable from any of the comma-separated `modules`, outlining each in its own color")
+	flagCPUProfile       = flag.String("cpuprofile", "", "write a CPU profile to `file`")
+	flagMemProfile       = flag.String("memprofile", "", "write a heap profile to `file` on exit")
+	flagMulti            = flag.String("multi", "", "load the modules in each of the comma-separated `dirs` and show their combined graph")
+	flagInternalCycles   = flag.Bool("fail-if-internal-cycle", false, "exit with a non-zero status if packages in the main module import each other in a cycle; requires -granularity package")
+	flagSummaryMarkdown  = flag.Bool("summary-markdown", false, "write a markdown table of test-only modules followed by the mermaid graph, for CI job summaries (same as -format markdown)")
+	flagStripMajor       = flag.Bool("strip-version-suffix", false, "show a module's major version suffix such as /v2 as a badge after its path in labels")
+	flagQuietErrors      = flag.Bool("quiet-errors", false, "write the graph despite package load errors, listing them in a comment in mermaid output")
+	flagRedact           = flag.String("redact", "", "hide the paths of modules matching `regexp`, replacing each with a numbered token; the mapping is printed to standard error")
+	flagTestContext      = flag.Bool("test-context", false, "show only test-only modules and the modules whose tests introduce them, labeling the edges between the two")
+	flagDotRecords       = flag.Bool("dot-records", false, "draw each node in dot output as a record showing its path, version and class")
+	flagExplainColor     = flag.String("explain-color", "", "print why `module` is drawn in the color it is, instead of the graph")
+	flagGroupBy          = flag.String("group-by", "", "draw modules in boxes grouped by `mode`: host, org (the first two path elements) or license (the family of license detected in each module)")
+	flagSplitByGroup     = flag.Bool("split-by-group", false, "with -group-by, write each group and its edges to its own file in the -o directory")
+	flagDotEngine        = flag.String("dot-engine", "dot", "Graphviz layout `engine` for dot output: dot, neato, fdp or sfdp")
+	flagBaselineEdges    = flag.String("baseline-edges", "", "highlight edges not listed in `file`, such as porcelain output or the output of go mod graph")
+	flagOffline          = flag.Bool("offline", false, "load using only the module cache, without network access; modules missing from the cache cause an error")
+	flagVersion          = flag.Bool("version", false, "print the version of this program and exit")
+	flagAbbrev           = flag.Bool("abbrev", false, "label modules with the last element of their paths, adding preceding elements where needed to keep labels distinct")
+	flagUsageInMain      = flag.Bool("usage-in-main", false, "annotate each directly required module with the number of non-test main module packages that import it")
+	flagPruneUnreachable = flag.Bool("prune-unreachable", false, "omit modules that cannot be reached from the main module")
+	flagPropagateTests   = flag.Bool("propagate-test-only", false, "also mark as test-only any module reachable only through test-only modules, noting each one affected")
+	flagReport           = flag.String("report", "", "also write a JSON report on each module to its own file in `dir`")
+	flagLegendInline     = flag.Bool("legend-inline", false, "follow the mermaid code block with a markdown legend describing the node classes")
+	flagBroken           = flag.Bool("broken", false, "continue despite load errors, highlighting and listing modules none of whose packages loaded cleanly")
+	flagTestFirst        = flag.Bool("test-first", false, "declare test-only modules before the others in mermaid output")
+	flagJSONSchema       = flag.Bool("json-schema", false, "print a JSON Schema describing the -format json output and exit")
+	flagDirtyOnly        = flag.Bool("dirty-only", false, "show only modules whose requirements have uncommitted changes in go.mod, with their neighbors")
+	flagEdgeStyle        = flag.String("edge-style", "solid", "mermaid arrow `style`: solid, dotted or thick, or per kind of edge as in prod=solid,test=dotted")
+	flagLongestPath      = flag.Bool("longest-path", false, "print and highlight the longest chain of dependencies from the main module")
+	flagGroupByLicense   = flag.Bool("group-by-license", false, "shorthand for -group-by license")
+	flagIgnoreTestFiles  = flag.String("ignore-test-files", "", "disregard imports made only by test files whose base names match `regexp`")
+	flagRankByDepth      = flag.Bool("rank-by-depth", false, "draw the modules at each distance from the main module together in a Level box")
+	flagOnlyTestEdges    = flag.Bool("only-test-edges", false, "show only the edges found solely when loading tests, with the modules at either end")
+	flagNodeID           = flag.String("node-id", "index", "form of mermaid node identifiers: `index` (by position), path (derived from the module path) or hash (of the module path)")
+	flagVuln             = flag.Bool("vuln", false, "highlight and annotate modules with known vulnerabilities, using govulncheck if installed or else the OSV database")
+	flagFocusPath        = flag.String("focus-path", "", "highlight every chain of dependencies from one module to another, given as `src,dst`")
+	flagFocusPathOnly    = flag.Bool("focus-path-only", false, "with -focus-path, show only the modules on the highlighted paths")
+	flagClassesJSON      = flag.Bool("classes-json", false, "after the graph, print a JSON object holding the number of modules in each class to standard error")
+	flagMergeBidi        = flag.Bool("merge-bidirectional", false, "draw each pair of modules that depend on each other with a single double-headed mermaid link")
+	flagScanGenerate     = flag.Bool("scan-generate", false, "highlight modules providing tools run by go:generate directives in the main module, with a dotted edge from the main module")
+	flagThemeFile        = flag.String("theme-file", "", "use the JSON object in `file` as the mermaid themeVariables, with the base theme")
+	flagDominators       = flag.Bool("dominators", false, "list the modules that each module is the only route to from the main module, highlighting those with any")
+	flagColorsFile       = flag.String("colors-file", "", "fill modules matching the patterns in `file`, one regexp=#rrggbb per line, with the given colors")
+	flagHideMainPkgs     = flag.Bool("hide-main-packages", false, "with -granularity package, draw the main module as a single node")
+	flagTrimPrefix       = flag.String("trim-prefix", "", "remove `prefix` from the start of the labels of modules whose paths begin with it")
+	flagSideBySide       = flag.Bool("side-by-side", false, "write mermaid diagrams of the graph without and with tests, one after the other")
+	flagPruneByIndegree  = flag.Int("prune-by-indegree", 0, "hide modules with at least `n` incoming edges, listing them in a comment in mermaid output")
+	flagShapes           = flag.Bool("shapes", false, "draw mermaid nodes in a shape depending on their class as well as a color: a stadium for the main module and a hexagon for test-only modules")
+	flagToolCycles       = flag.Bool("check-tool-cycles", false, "exit with a non-zero status if a module providing a tool depends on the module using it; requires -tools or -scan-generate")
+	flagStatsCSV         = flag.String("stats-csv", "", "append a row of timestamped summary figures about the graph to the CSV `file`, creating it if needed")
+	flagCodeowners       = flag.String("codeowners", "", "color modules by their owners as given by the CODEOWNERS-style `file` of module path patterns")
+	flagPackage          = flag.String("package", "", "show only the dependencies of the single package `path` and its tests, instead of all")
+	flagIndirectBlame    = flag.Bool("indirect-blame", false, "list the direct requirements through which each indirect module enters the graph, also showing them as mermaid tooltips")
+	flagWatchGit         = flag.Bool("watch-git", false, "keep running, writing the graph to -o again whenever the checked-out git commit changes")
+	flagLocalReplace     = flag.Bool("annotate-replace-local", false, "highlight and list modules replaced by a local directory; with -strict, exit with an error if there are any")
+	flagOnlyNewInTests   = flag.Bool("only-new-in-tests", false, "highlight the packages that appear only when tests are compiled; requires -granularity package")
+	flagRequireComments  = flag.Bool("show-require-comments", false, "show the trailing comments of the direct requirements in go.mod as node tooltips")
+	flagFanoutReport     = flag.Bool("fanout-report", false, "instead of drawing the graph, print each direct requirement with the number of modules that only it brings in and the number it reaches in all")
+	flagRankSep          = flag.Float64("ranksep", 0, "with -format dot, the Graphviz ranksep in `inches` between ranks; 0 uses the Graphviz default")
+	flagNodeSep          = flag.Float64("nodesep", 0, "with -format dot, the Graphviz nodesep in `inches` between nodes in a rank; 0 uses the Graphviz default")
+	flagCollapseTestOnly = flag.Bool("collapse-test-only", false, "draw all test-only modules as a single "+testDepsNode+" node")
+	flagWeightBySize     = flag.Bool("weight-by-size", false, "draw each edge with a width that grows with the size on disk of the module it leads to")
+	flagTestFileReport   = flag.Bool("filter-test-edges-by-file", false, "instead of drawing the graph, print each test file with the test-only modules that its imports lead to")
+	flagSuppressFile     = flag.String("suppress-file", "", "with -filter-test-edges-by-file, also show which test-only modules would remain if the test files whose base names match `regexp` were absent")
+	flagConflicts        = flag.Bool("conflicts", false, "list modules resolved at more than one version, with the importers of each, instead of the graph")
+)
+
+var (
+	flagLayers          layerRules
+	flagAliasGroups     aliasGroups
+	flagCollapseModules moduleList
+	flagBranchDepths    branchDepthRules
+)
+
+func init() {
+	flag.Var(&flagLayers, "layer", "assign modules matching `regexp=name` to a layer; repeat to rank layers from highest to lowest")
+	flag.Var(&flagAliasGroups, "alias-group", "show modules matching `name=regexp` as a single module called name; may be repeated")
+	flag.Var(&flagBranchDepths, "max-depth-per-branch", "show modules at most `regexp=depth` levels below any module matching regexp; may be repeated")
+	flag.Var(&flagCollapseModules, "collapse-module", "with -granularity package, draw the `module` as a single node; may be repeated")
+}
+
+// repeatedValue is implemented by the values of flags that may be
+// given more than once. The values method returns the argument of
+// each occurrence, in order.
+type repeatedValue interface {
+	flag.Value
+	values() []string
+}
+
+// moduleList implements flag.Value for flags naming
+// a module that may be given more than once.
+type moduleList []string
+
+func (l *moduleList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *moduleList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+func (l *moduleList) values() []string {
+	return slices.Clone(*l)
+}
+
+// contains reports whether l holds the given module.
+func (l moduleList) contains(module string) bool {
+	return slices.Contains(l, module)
+}
+
+// outputFormat describes an output format.
+type outputFormat struct {
+	// write writes the graph in this format.
+	write func(io.Writer, *graph)
+
+	// ext holds the file name extension used when
+	// writing several formats at once.
+	ext string
+}
+
+// formats maps each supported -format value to its description.
+var formats = map[string]outputFormat{
+	"mermaid":       {writeDot, ".mmd"},
+	"tree":          {writeTree, ".txt"},
+	"json":          {writeJSON, ".json"},
+	"html":          {writeHTML, ".html"},
+	"svg":           {writeSVG, ".svg"},
+	"canonical":     {writeCanonical, ".canonical"},
+	"go":            {writeGo, ".go"},
+	"markdown":      {writeMarkdown, ".md"},
+	"make":          {writeMake, ".mk"},
+	"dot":           {writeGraphviz, ".dot"},
+	"mermaid-class": {writeMermaidClass, ".class.mmd"},
+	"cypher":        {writeCypher, ".cypher"},
+	"excalidraw":    {writeExcalidraw, ".excalidraw"},
+	"matrix":        {writeMatrix, ".matrix.tsv"},
+	"ndjson-events": {writeNDJSONEvents, ".ndjson"},
+
+	// porcelain is selected with -porcelain rather than -format
+	// so that its name alone signals its stability guarantee.
+	"porcelain": {writePorcelain, ".tsv"},
+}
+
+// edge represents a dependency of one module on another.
+type edge struct {
+	from, to string
+}
+
+// classOverlay is a mermaid class applied to a set of nodes on top of
+// their usual class. Overlays are declared after the usual classes
+// so that their styles take precedence.
+type classOverlay struct {
+	name  string
+	style string
+	nodes map[string]struct{}
+}
+
+// cluster is a set of nodes drawn together inside a labeled box.
+type cluster struct {
+	title string
+	nodes map[string]struct{}
+}
+
+// graph holds the module dependency graph derived from the loaded packages.
+type graph struct {
+	mainMod  string
+	nodes    map[string]struct{}
+	edges    map[string]map[string]struct{}
+	testOnly map[string]struct{}
+
+	// versions holds the selected version of each module.
+	// The main module has no version.
+	versions map[string]string
+
+	// replaces maps each replaced module to the path of
+	// its replacement, which may be a local directory.
+	replaces map[string]string
+
+	// localReplaces holds the replaced modules whose
+	// replacement is a local directory.
+	localReplaces map[string]struct{}
+
+	// noDeps records that the main module was found
+	// to have no dependencies on other modules.
+	noDeps bool
+
+	// testOnlyPkgs holds the packages that appear only in the
+	// load with tests. It is empty unless nodes are packages.
+	testOnlyPkgs map[string]struct{}
+
+	// pkgCounts holds the number of packages used from each module.
+	pkgCounts map[string]int
+
+	// prodEdges holds the edges derived from the load
+	// without tests.
+	prodEdges map[string]map[string]struct{}
+
+	// extraEdges holds edges that are drawn in mermaid output
+	// in addition to those in edges, such as those to tools.
+	extraEdges []labeledEdge
+
+	// edgeLabels holds any text shown on an edge in mermaid output.
+	edgeLabels map[edge]string
+
+	// edgeStyles holds any extra mermaid link style for an edge.
+	edgeStyles map[edge]string
+
+	// counts holds the number of distinct package imports
+	// that contribute to each edge.
+	counts map[edge]int
+
+	// overlays holds classes applied to nodes in addition
+	// to the class that determines their fill color.
+	overlays []classOverlay
+
+	// clusters holds groups of nodes that are drawn together
+	// inside a labeled box. A node belongs to at most one cluster.
+	clusters []cluster
+
+	// shortNames holds the name shown in place of
+	// each node's full path in its label, if any.
+	shortNames map[string]string
+
+	// otherMains holds the main modules of any other
+	// graphs that were merged into this one by -multi.
+	otherMains []string
+
+	// notes holds extra annotations appended to a node's label.
+	notes map[string][]string
+
+	// deprecated holds the deprecation message of each
+	// module that is marked as deprecated.
+	deprecated map[string]string
+
+	// retracted holds the rationale for the retraction of each
+	// module whose selected version has been retracted.
+	retracted map[string]string
+
+	// dirs holds the directory containing each module's
+	// source, if it is available locally.
+	dirs map[string]string
+
+	// tooltips holds text to show when hovering over a node.
+	tooltips map[string]string
+
+	// broken holds the modules none of whose
+	// packages could be loaded without errors.
+	broken map[string]struct{}
+
+	// mainUsage holds the number of non-test packages in the
+	// main module that import packages from each other module.
+	mainUsage map[string]int
+
+	// loadErrors holds the errors encountered while loading packages.
+	loadErrors []string
+
+	// conflicts holds any module resolved at more than one version,
+	// as returned by findConflicts.
+	conflicts map[string]map[string][]string
+
+	// hidden holds the modules removed by -prune-by-indegree.
+	hidden []string
+}
+
 func main() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "usage: gotestdeps\n")
 		fmt.Fprintf(os.Stderr, `
 Command gotestdeps prints the Go module dependency graph, highlighting
 in red the modules that are present only because of tests.
+
+The default for any flag can be set with an environment variable named
+GOTESTDEPS_ followed by the flag name in upper case with hyphens replaced
+by underscores; for example GOTESTDEPS_FORMAT=tree or
+GOTESTDEPS_COLOR_TEST=#ff0000. Flags given on the command line take
+precedence over environment variables, which take precedence over the
+built-in defaults.
+
 `)
+		flag.PrintDefaults()
 	}
 	flag.Parse()
+	setFlagsFromEnv(flag.CommandLine, os.LookupEnv)
+	if *flagVersion {
+		fmt.Println("gotestdeps", toolVersion())
+		return
+	}
+	if *flagJSONSchema {
+		writeJSONSchema(os.Stdout)
+		return
+	}
+	startProfiles()
+	defer stopProfiles()
+	switch *flagSortEdges {
+	case "name", "count":
+	default:
+		log.Fatalf("invalid -sort-edges value %q (want name or count)", *flagSortEdges)
+	}
+	if err := parseEdgeStyle(*flagEdgeStyle); err != nil {
+		log.Fatalf("invalid -edge-style value %q: %v", *flagEdgeStyle, err)
+	}
+	if *flagPorcelain {
+		*flagFormat = "porcelain"
+	}
+	if *flagSummaryMarkdown {
+		*flagFormat = "markdown"
+	}
+	formatNames := strings.Split(*flagFormat, ",")
+	usesMermaid, usesDot := false, false
+	for _, name := range formatNames {
+		if _, ok := formats[name]; !ok {
+			log.Fatalf("unknown -format %q", name)
+		}
+		usesMermaid = usesMermaid || name == "mermaid" || name == "html"
+		usesDot = usesDot || name == "dot"
+	}
+	switch *flagRenderer {
+	case "", "dagre", "elk":
+	default:
+		log.Fatalf("invalid -renderer value %q (want dagre or elk)", *flagRenderer)
+	}
+	if *flagColorsFile != "" {
+		rules, err := readColorRules(*flagColorsFile)
+		if err != nil {
+			log.Fatalf("cannot read colors: %v", err)
+		}
+		registerColorRules(rules)
+	}
+	if *flagCodeowners != "" {
+		rules, err := readCodeowners(*flagCodeowners)
+		if err != nil {
+			log.Fatalf("cannot read owners: %v", err)
+		}
+		registerOwnerColors(rules)
+	}
+	if *flagThemeFile != "" {
+		data, err := os.ReadFile(*flagThemeFile)
+		if err != nil {
+			log.Fatalf("cannot read theme: %v", err)
+		}
+		var vars map[string]any
+		if err := json.Unmarshal(data, &vars); err != nil {
+			log.Fatalf("invalid theme file %s: %v", *flagThemeFile, err)
+		}
+		themeVariables = vars
+	}
+	if *flagRenderer != "" && !usesMermaid {
+		log.Fatalf("-renderer applies only to the mermaid and html formats")
+	}
+	if *flagThemeFile != "" && !usesMermaid {
+		log.Fatalf("-theme-file applies only to the mermaid and html formats")
+	}
+	if *flagRankSep < 0 || *flagNodeSep < 0 {
+		log.Fatalf("-ranksep and -nodesep must not be negative")
+	}
+	if (*flagRankSep != 0 || *flagNodeSep != 0) && !usesDot {
+		log.Fatalf("-ranksep and -nodesep apply only to the dot format")
+	}
+	switch *flagDotEngine {
+	case "dot", "neato", "fdp", "sfdp":
+	default:
+		log.Fatalf("invalid -dot-engine value %q (want dot, neato, fdp or sfdp)", *flagDotEngine)
+	}
+	if *flagGroupByLicense {
+		*flagGroupBy = "license"
+	}
+	switch *flagGroupBy {
+	case "", "host", "org", "license":
+	default:
+		log.Fatalf("invalid -group-by value %q (want host, org or license)", *flagGroupBy)
+	}
+	if *flagGroupBy != "" && *flagGroupTestOnly {
+		log.Fatalf("-group-by cannot be used with -group-test-only")
+	}
+	if src, dst, ok := strings.Cut(*flagFocusPath, ","); *flagFocusPath != "" && (!ok || src == "" || dst == "") {
+		log.Fatalf("invalid -focus-path value %q (want src,dst)", *flagFocusPath)
+	}
+	if *flagFocusPathOnly && *flagFocusPath == "" {
+		log.Fatalf("-focus-path-only requires -focus-path")
+	}
+	switch *flagNodeID {
+	case "index", "path", "hash":
+	default:
+		log.Fatalf("invalid -node-id value %q (want index, path or hash)", *flagNodeID)
+	}
+	if *flagOnlyTestEdges && *flagProdOnly {
+		log.Fatalf("-only-test-edges cannot be used with -prod-only")
+	}
+	if *flagRankByDepth && (*flagGroupBy != "" || *flagGroupTestOnly) {
+		log.Fatalf("-rank-by-depth cannot be used with -group-by or -group-test-only")
+	}
+	if *flagSplitByGroup && (*flagGroupBy == "" || *flagOutput == "") {
+		log.Fatalf("-split-by-group requires -group-by and an -o directory")
+	}
+	if len(formatNames) > 1 && *flagOutput == "" && !*flagSplitByGroup {
+		log.Fatalf("-o is required when writing more than one format")
+	}
+	if *flagSideBySide && (*flagFormat != "mermaid" || *flagSplitByGroup) {
+		log.Fatalf("-side-by-side requires -format mermaid and cannot be used with -split-by-group")
+	}
+	if *flagClipboard && *flagOutput != "" {
+		log.Fatalf("-clipboard cannot be used with -o")
+	}
 
+	checkPlatform()
+	if !token.IsIdentifier(*flagGoPackage) || *flagGoPackage == "_" {
+		log.Fatalf("invalid -go-package value %q: not a valid package name", *flagGoPackage)
+	}
+	if *flagIgnoreTestFiles != "" {
+		pattern, err := regexp.Compile(*flagIgnoreTestFiles)
+		if err != nil {
+			log.Fatalf("invalid -ignore-test-files pattern: %v", err)
+		}
+		ignoredTestFiles = pattern
+	}
+	var redactPattern *regexp.Regexp
+	if *flagRedact != "" {
+		pattern, err := regexp.Compile(*flagRedact)
+		if err != nil {
+			log.Fatalf("invalid -redact pattern: %v", err)
+		}
+		redactPattern = pattern
+	}
+	switch *flagGranularity {
+	case "module", "package":
+	default:
+		log.Fatalf("invalid -granularity value %q (want module or package)", *flagGranularity)
+	}
+	if *flagIncludeStdlib && *flagGranularity != "package" {
+		log.Fatalf("-include-stdlib requires -granularity package")
+	}
+	if *flagInternalCycles && *flagGranularity != "package" {
+		log.Fatalf("-fail-if-internal-cycle requires -granularity package")
+	}
+	if *flagCollapseStd && !*flagIncludeStdlib {
+		log.Fatalf("-collapse-std requires -include-stdlib")
+	}
+	if len(flagCollapseModules) > 0 && *flagGranularity != "package" {
+		log.Fatalf("-collapse-module requires -granularity package")
+	}
+	if *flagToolCycles && !*flagTools && !*flagScanGenerate {
+		log.Fatalf("-check-tool-cycles requires -tools or -scan-generate")
+	}
+	if *flagOnlyNewInTests && *flagGranularity != "package" {
+		log.Fatalf("-only-new-in-tests requires -granularity package")
+	}
+	if *flagHideMainPkgs && *flagGranularity != "package" {
+		log.Fatalf("-hide-main-packages requires -granularity package")
+	}
+	switch *flagTestOnlyMode {
+	case "transitive", "direct":
+	default:
+		log.Fatalf("invalid -test-only-mode value %q (want transitive or direct)", *flagTestOnlyMode)
+	}
+	if *flagSample < 0 || *flagSample > 1 {
+		log.Fatalf("invalid -sample value %v (want a probability between 0 and 1)", *flagSample)
+	}
+	if *flagVersionBelow != "" && !semver.IsValid(*flagVersionBelow) {
+		log.Fatalf("invalid -version-below value %q: not a semantic version", *flagVersionBelow)
+	}
+
+	if *flagRequireFile != "" && (*flagExplainTestOnly != "" || *flagConflicts || *flagDiffFocus != "") {
+		log.Fatalf("-require-file cannot be used with -explain-testonly, -conflicts or -diff-focus")
+	}
+	if *flagMulti != "" && (*flagRequireFile != "" || *flagExplainTestOnly != "" || *flagDiffFocus != "") {
+		log.Fatalf("-multi cannot be used with -require-file, -explain-testonly or -diff-focus")
+	}
+	if *flagTestFileReport && (*flagMulti != "" || *flagRequireFile != "") {
+		log.Fatalf("-filter-test-edges-by-file cannot be used with -multi or -require-file")
+	}
+	var suppressedFiles *regexp.Regexp
+	if *flagSuppressFile != "" {
+		if !*flagTestFileReport {
+			log.Fatalf("-suppress-file requires -filter-test-edges-by-file")
+		}
+		pattern := *flagSuppressFile
+		if ignoredTestFiles != nil {
+			pattern = "(?:" + ignoredTestFiles.String() + ")|(?:" + pattern + ")"
+		}
+		var err error
+		if suppressedFiles, err = regexp.Compile(pattern); err != nil {
+			log.Fatalf("invalid -suppress-file pattern: %v", err)
+		}
+	}
+
+	if *flagWatchGit {
+		if *flagOutput == "" {
+			log.Fatalf("-watch-git requires -o")
+		}
+		watchGit()
+	}
+
+	patterns := []string{"all"}
+	if *flagPackage != "" {
+		if *flagPatternsFrom != "" {
+			log.Fatalf("-package cannot be used with -patterns-from")
+		}
+		if strings.Contains(*flagPackage, "...") || *flagPackage == "all" || *flagPackage == "std" || *flagPackage == "cmd" {
+			log.Fatalf("-package requires a single import path, not the pattern %q", *flagPackage)
+		}
+		patterns = []string{*flagPackage}
+	}
+	if *flagPatternsFrom != "" {
+		var err error
+		if patterns, err = readPatternsFile(*flagPatternsFrom); err != nil {
+			log.Fatalf("cannot read patterns: %v", err)
+		}
+		if len(patterns) == 0 {
+			log.Fatalf("no patterns found in %s", *flagPatternsFrom)
+		}
+	}
+	var g *graph
+	if *flagMulti != "" {
+		var gs []*graph
+		for _, dir := range strings.Split(*flagMulti, ",") {
+			mg, _ := loadGraph(dir, patterns)
+			gs = append(gs, mg)
+		}
+		g = mergeGraphs(gs)
+	} else if *flagRequireFile != "" {
+		var err error
+		if g, err = readRequireFile(*flagRequireFile); err != nil {
+			log.Fatalf("cannot read -require-file: %v", err)
+		}
+	} else if *flagCache != "" && *flagExplainTestOnly == "" && !*flagTestFileReport {
+		// Explanations and reports by file need the
+		// packages themselves, which are not cached.
+		g = readCache(*flagCache, patterns)
+	}
+	if g == nil {
+		var testPkgs []*packages.Package
+		g, testPkgs = loadGraph("", patterns)
+		// Graphs from trees with errors are not cached
+		// so that a later run without -quiet-errors
+		// still reports them.
+		if *flagCache != "" && len(g.loadErrors) == 0 {
+			writeCache(*flagCache, patterns, g, testPkgs)
+		}
+		if *flagExplainTestOnly != "" {
+			if err := explainTestOnly(os.Stdout, g, testPkgs, *flagExplainTestOnly); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+		if *flagTestFileReport {
+			var without *graph
+			if suppressedFiles != nil {
+				ignoredTestFiles = suppressedFiles
+				without, _ = loadGraph("", patterns)
+			}
+			writeTestFileReport(os.Stdout, g, testPkgs, without)
+			return
+		}
+	}
+	if *flagConflicts {
+		if writeConflicts(os.Stdout, g) && *flagStrict {
+			stopProfiles()
+			os.Exit(1)
+		}
+		return
+	}
+	if *flagExplainColor != "" {
+		if err := explainColor(os.Stdout, g, *flagExplainColor); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if *flagDeepTestReport {
+		writeDeepTestReport(os.Stdout, g)
+		return
+	}
+	if *flagFanoutReport {
+		writeFanoutReport(os.Stdout, g)
+		return
+	}
+	checkCaseCollisions(g)
+	mains := append([]string{g.mainMod}, g.otherMains...)
+	onlyMains := len(g.nodes) == len(mains)
+	for _, m := range mains {
+		_, ok := g.nodes[m]
+		onlyMains = onlyMains && ok
+	}
+	if g.noDeps || onlyMains {
+		// A graph with just the main modules looks broken,
+		// so say that it is what was expected.
+		g.noDeps = true
+		verb := "has"
+		if len(mains) > 1 {
+			verb = "have"
+		}
+		log.Printf("note: %s %s no external module dependencies", strings.Join(mains, ", "), verb)
+	}
+	if *flagDiffFocus != "" {
+		other, _ := loadGraph(*flagDiffFocus, patterns)
+		changed := changedModules(other, g)
+		g.keepNodes(g.neighborhood(changed))
+	}
+	if *flagDirtyOnly {
+		dirty, err := dirtyModules()
+		if err != nil {
+			log.Fatalf("cannot use -dirty-only: %v", err)
+		}
+		log.Printf("note: %d modules with uncommitted requirement changes", len(dirty))
+		g.keepNodes(g.neighborhood(intersection(dirty, g.nodes)))
+	}
+	if *flagPropagateTests {
+		for _, n := range g.propagateTestOnly() {
+			log.Printf("note: marking %s as test-only as it is reachable only through test-only modules", n)
+		}
+	}
+	if *flagProdOnly {
+		g.edges = g.prodEdges
+		g.keepNodes(difference(g.nodes, g.testOnly))
+	}
+	if *flagOnlyTestEdges {
+		keep := make(map[string]struct{})
+		for from, tos := range g.edges {
+			for to := range tos {
+				if _, ok := g.prodEdges[from][to]; ok {
+					delete(tos, to)
+					continue
+				}
+				keep[from] = struct{}{}
+				keep[to] = struct{}{}
+			}
+		}
+		g.keepNodes(keep)
+	}
+	if len(flagAliasGroups) > 0 {
+		g.mergeAliases(flagAliasGroups)
+	}
+	if *flagShowReplaceEdges {
+		g.addReplaceEdges()
+	}
+	if *flagNoEdgesToMain {
+		for _, tos := range g.edges {
+			delete(tos, g.mainMod)
+		}
+	}
+	if *flagRoot != "" {
+		if _, ok := g.nodes[*flagRoot]; !ok {
+			log.Fatalf("root module %q not found in dependency graph", *flagRoot)
+		}
+		g.keepNodes(g.reachable(*flagRoot))
+		g.mainMod = *flagRoot
+	}
+	if *flagReachableFrom != "" {
+		seeds := strings.Split(*flagReachableFrom, ",")
+		for _, s := range seeds {
+			if _, ok := g.nodes[s]; !ok {
+				log.Fatalf("seed module %q not found in dependency graph", s)
+			}
+		}
+		g.keepNodes(g.reachable(seeds...))
+		for i, s := range seeds {
+			g.overlays = append(g.overlays, classOverlay{
+				name:  fmt.Sprintf("seed%d", i),
+				style: fmt.Sprintf("stroke:%s,stroke-width:4px", seedColors[i%len(seedColors)]),
+				nodes: map[string]struct{}{s: {}},
+			})
+		}
+	}
+	if *flagPruneSubtree != "" {
+		if _, ok := g.nodes[*flagPruneSubtree]; !ok {
+			log.Fatalf("module %q not found in dependency graph", *flagPruneSubtree)
+		}
+		g.pruneSubtree(*flagPruneSubtree)
+	}
+	if len(flagBranchDepths) > 0 {
+		g.pruneBranches(flagBranchDepths)
+	}
+	if *flagPruneUnreachable {
+		g.keepNodes(g.reachable(append([]string{g.mainMod}, g.otherMains...)...))
+	}
+	if *flagTestContext {
+		g.showTestContext()
+	}
+	if *flagVersions || *flagShortVersions {
+		for n := range g.nodes {
+			if v := g.versions[n]; v != "" {
+				if *flagShortVersions {
+					v = shortVersion(v)
+				}
+				g.notes[n] = append(g.notes[n], v)
+			}
+		}
+	}
+	if *flagSizes {
+		for n := range g.nodes {
+			g.notes[n] = append(g.notes[n], moduleSize(g.dirs[n]))
+		}
+	}
+	if *flagRequireComments {
+		comments, err := requireComments()
+		if err != nil {
+			log.Fatalf("cannot read requirements: %v", err)
+		}
+		for n, c := range comments {
+			if _, ok := g.nodes[n]; ok {
+				g.addTooltip(n, c)
+			}
+		}
+	}
+	if *flagUsageInMain {
+		direct, err := directRequirements()
+		if err != nil {
+			log.Fatalf("cannot read requirements: %v", err)
+		}
+		for n := range direct {
+			if _, ok := g.nodes[n]; ok {
+				g.notes[n] = append(g.notes[n], fmt.Sprintf("(used by %d)", g.mainUsage[n]))
+			}
+		}
+	}
+	if *flagPackageCounts {
+		for n := range g.nodes {
+			g.notes[n] = append(g.notes[n], fmt.Sprintf("[%dp]", g.pkgCounts[n]))
+		}
+	}
+	if *flagCompact {
+		g.collapseLeaves("leaves", func(name string) bool {
+			_, isTestOnly := g.testOnly[name]
+			return !isTestOnly
+		})
+	}
+	if *flagPruneTestLeaves {
+		g.collapseLeaves("test leaves", func(name string) bool {
+			_, isTestOnly := g.testOnly[name]
+			return isTestOnly
+		})
+	}
+	if *flagCollapseTestOnly {
+		g.collapseTestOnly()
+	}
+	if *flagPruneByIndegree > 0 {
+		g.hideUbiquitous(*flagPruneByIndegree)
+		if len(g.hidden) > 0 {
+			log.Printf("note: hidden ubiquitous modules: %s", strings.Join(g.hidden, ", "))
+		}
+	}
+	if *flagSample < 1 {
+		g.sampleEdges(*flagSample)
+	}
+	if *flagGroupBy != "" && !*flagSplitByGroup {
+		groups := g.groups(*flagGroupBy)
+		for _, name := range sortedKeys(groups) {
+			g.clusters = append(g.clusters, cluster{title: name, nodes: groups[name]})
+		}
+	}
+	if _, ok := g.nodes[g.mainMod]; ok && *flagRankByDepth {
+		var levels []map[string]struct{}
+		for n, d := range g.depths(g.mainMod) {
+			for len(levels) <= d {
+				levels = append(levels, make(map[string]struct{}))
+			}
+			levels[d][n] = struct{}{}
+		}
+		for d, nodes := range levels {
+			g.clusters = append(g.clusters, cluster{title: fmt.Sprintf("Level %d", d), nodes: nodes})
+		}
+	}
+	if *flagGroupTestOnly {
+		nodes := make(map[string]struct{})
+		for n := range g.testOnly {
+			if _, ok := g.nodes[n]; ok && n != g.mainMod {
+				nodes[n] = struct{}{}
+			}
+		}
+		if len(nodes) > 0 {
+			g.clusters = append(g.clusters, cluster{
+				title: "Test-only dependencies",
+				nodes: nodes,
+			})
+		}
+	}
+	if *flagTools {
+		goMod, err := goModFile()
+		if err != nil {
+			log.Fatal(err)
+		}
+		tools, err := toolModules(goMod)
+		if err != nil {
+			log.Fatalf("cannot read tools: %v", err)
+		}
+		g.addToolEdges(tools)
+	}
+	if *flagScanGenerate {
+		goMod, err := goModFile()
+		if err != nil {
+			log.Fatal(err)
+		}
+		dir := filepath.Dir(goMod)
+		tools, err := generateTools(dir)
+		if err != nil {
+			log.Fatalf("cannot scan for go:generate directives: %v", err)
+		}
+		g.addGenerateToolEdges(generateToolModules(dir, tools))
+	}
+	if *flagAbbrev {
+		g.shortNames = g.abbreviations()
+		for n, short := range g.shortNames {
+			if short != n {
+				g.addTooltip(n, n)
+			}
+		}
+	}
+	if *flagMaxLabelLen > 0 {
+		for n := range g.nodes {
+			if truncateMiddle(n, *flagMaxLabelLen) != n {
+				g.addTooltip(n, n)
+			}
+		}
+	}
+	if *flagBundleCommon > 0 {
+		g.fadeCommonEdges(*flagBundleCommon)
+	}
+	if *flagWeightBySize {
+		g.weightBySize()
+	}
+	if *flagBaseline != "" {
+		baseline, err := readModuleList(*flagBaseline)
+		if err != nil {
+			log.Fatalf("cannot read baseline: %v", err)
+		}
+		g.overlays = append(g.overlays, classOverlay{
+			name:  "newDep",
+			style: newDepStyle,
+			nodes: difference(g.nodes, baseline),
+		})
+	}
+	if *flagDeprecations {
+		nodes := make(map[string]struct{})
+		for n := range g.nodes {
+			if msg, ok := g.deprecated[n]; ok {
+				nodes[n] = struct{}{}
+				g.addTooltip(n, "Deprecated: "+msg)
+			}
+		}
+		g.overlays = append(g.overlays, classOverlay{
+			name:  "deprecatedDep",
+			style: deprecatedStyle,
+			nodes: nodes,
+		})
+	}
+	if *flagVuln && *flagOffline {
+		log.Printf("note: -vuln needs network access, so is ignored with -offline")
+	} else if *flagVuln {
+		vulns, err := g.vulnerabilities()
+		if err != nil {
+			log.Printf("cannot check for vulnerabilities: %v", err)
+		}
+		nodes := make(map[string]struct{})
+		for n, ids := range vulns {
+			if _, ok := g.nodes[n]; !ok {
+				continue
+			}
+			nodes[n] = struct{}{}
+			g.notes[n] = append(g.notes[n], fmt.Sprintf("(%d vulns)", len(ids)))
+			g.addTooltip(n, "Vulnerabilities: "+strings.Join(ids, ", "))
+		}
+		g.overlays = append(g.overlays, classOverlay{
+			name:  "vulnerableDep",
+			style: vulnStyle,
+			nodes: nodes,
+		})
+	}
+	if *flagRetractions {
+		nodes := make(map[string]struct{})
+		for n := range g.nodes {
+			if msg, ok := g.retracted[n]; ok {
+				nodes[n] = struct{}{}
+				tip := "Retracted: " + g.versions[n]
+				if msg != "" {
+					tip += ": " + msg
+				}
+				g.addTooltip(n, tip)
+			}
+		}
+		g.overlays = append(g.overlays, classOverlay{
+			name:  "retractedVersionDep",
+			style: retractedStyle,
+			nodes: nodes,
+		})
+	}
+	if *flagBaselineEdges != "" {
+		baseline, err := readEdgeList(*flagBaselineEdges)
+		if err != nil {
+			log.Fatalf("cannot read baseline edges: %v", err)
+		}
+		for from, tos := range g.edges {
+			for to := range tos {
+				if e := (edge{from, to}); !baseline[e] {
+					g.edgeStyles[e] = newEdgeStyle
+				}
+			}
+		}
+	}
+	if *flagBroken {
+		broken := intersection(g.broken, g.nodes)
+		for _, n := range sortedKeys(broken) {
+			log.Printf("broken: no package in %s loaded without errors", n)
+		}
+		g.overlays = append(g.overlays, classOverlay{
+			name:  "brokenDep",
+			style: brokenStyle,
+			nodes: broken,
+		})
+	}
+	if *flagLongestPath {
+		showLongestPath(g)
+	}
+	if *flagDominators {
+		showDominators(g)
+	}
+	if *flagIndirectBlame {
+		showIndirectBlame(g)
+	}
+	if *flagFocusPath != "" {
+		src, dst, _ := strings.Cut(*flagFocusPath, ",")
+		showFocusPaths(g, src, dst, *flagFocusPathOnly)
+	}
+	if *flagVersionBelow != "" {
+		g.overlays = append(g.overlays, classOverlay{
+			name:  "outdatedCandidate",
+			style: outdatedStyle,
+			nodes: g.versionsBelow(*flagVersionBelow),
+		})
+	}
+	if *flagShowPseudo {
+		pseudo := g.pseudoVersions()
+		for _, n := range sortedKeys(pseudo) {
+			log.Printf("pseudo-version: %s %s", n, g.versions[n])
+		}
+		g.overlays = append(g.overlays, classOverlay{
+			name:  "pseudoVersionDep",
+			style: pseudoVersionStyle,
+			nodes: pseudo,
+		})
+	}
+	if *flagOnlyNewInTests {
+		g.overlays = append(g.overlays, classOverlay{
+			name:  "testOnlyPackage",
+			style: testOnlyPackageStyle,
+			nodes: intersection(g.testOnlyPkgs, g.nodes),
+		})
+	}
+	if *flagLocalReplace {
+		local := intersection(g.localReplaces, g.nodes)
+		for _, n := range sortedKeys(local) {
+			log.Printf("local replacement: %s => %s", n, g.replaces[n])
+		}
+		g.overlays = append(g.overlays, classOverlay{
+			name:  "localReplaceDep",
+			style: localReplaceStyle,
+			nodes: local,
+		})
+		if len(local) > 0 && *flagStrict {
+			log.Fatalf("found %d modules replaced by a local directory", len(local))
+		}
+	}
+	violations := checkLayers(g)
+	cycles := 0
+	if *flagInternalCycles {
+		cycles = checkInternalCycles(g)
+	}
+	if *flagToolCycles {
+		cycles += checkToolCycles(g)
+	}
+	// Redaction comes last so that every earlier step sees, and
+	// everything written afterwards hides, the real paths.
+	if redactPattern != nil {
+		g.redact(redactPattern)
+	}
+
+	if *flagReport != "" {
+		writeReports(*flagReport, g)
+	}
+	if *flagSplitByGroup {
+		// Each group is written to its own file in the -o
+		// directory, named after the group and the format.
+		if err := os.MkdirAll(*flagOutput, 0o777); err != nil {
+			log.Fatal(err)
+		}
+		groups := g.groups(*flagGroupBy)
+		for _, group := range sortedKeys(groups) {
+			sub := g.groupGraph(group, groups[group])
+			for _, name := range formatNames {
+				f := formats[name]
+				writeOutput(filepath.Join(*flagOutput, fileNameEscaper.Replace(group)+f.ext), f.write, sub)
+			}
+		}
+	} else if *flagSideBySide {
+		writeOutput(*flagOutput, writeSideBySide, g)
+	} else if len(formatNames) == 1 {
+		writeOutput(*flagOutput, formats[formatNames[0]].write, g)
+	} else {
+		// Each format is written to its own file named
+		// by adding the format's extension to -o.
+		for _, name := range formatNames {
+			f := formats[name]
+			writeOutput(*flagOutput+f.ext, f.write, g)
+		}
+	}
+	if *flagClassesJSON {
+		writeClassCounts(os.Stderr, g)
+	}
+	if *flagStatsCSV != "" {
+		if err := appendStats(*flagStatsCSV, g); err != nil {
+			log.Fatalf("cannot write stats: %v", err)
+		}
+	}
+	if violations > 0 && *flagEnforceLayers || cycles > 0 {
+		stopProfiles()
+		os.Exit(1)
+	}
+}
+
+// writeOutput writes g to the named file, or to standard
+// output if file is empty.
+func writeOutput(file string, write func(io.Writer, *graph), g *graph) {
+	var out io.Writer = os.Stdout
+	var outFile *os.File
+	var clipboard *bytes.Buffer
+	if file == "" && *flagClipboard {
+		clipboard = new(bytes.Buffer)
+		out = clipboard
+	}
+	if file != "" {
+		f, err := os.Create(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		out, outFile = f, f
+	}
+	if *flagCRLF {
+		out = &crlfWriter{w: out}
+	}
+	start := time.Now()
+	write(out, g)
+	if file == "" {
+		file = "standard output"
+	}
+	tracef("write to %s: %v", file, time.Since(start))
+	if outFile != nil {
+		if err := outFile.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if clipboard != nil {
+		if err := copyToClipboard(clipboard.Bytes()); err != nil {
+			log.Printf("warning: cannot copy to clipboard (%v); writing to standard output instead", err)
+			os.Stdout.Write(clipboard.Bytes())
+		}
+	}
+}
+
+// crlfWriter is an io.Writer that translates each newline written
+// to it into a carriage-return, newline pair, leaving alone those
+// that are already preceded by a carriage return, even when the
+// two are written separately.
+type crlfWriter struct {
+	w io.Writer
+	// lastCR records whether the last byte written was a carriage return.
+	lastCR bool
+}
+
+func (w *crlfWriter) Write(buf []byte) (int, error) {
+	out := make([]byte, 0, len(buf)+bytes.Count(buf, []byte("\n")))
+	for _, b := range buf {
+		if b == '\n' && !w.lastCR {
+			out = append(out, '\r')
+		}
+		out = append(out, b)
+		w.lastCR = b == '\r'
+	}
+	if _, err := w.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(buf), nil
+}
+
+// setFlagsFromEnv sets the value of each flag in fs from its
+// corresponding GOTESTDEPS_* environment variable, as found by
+// lookupEnv, if present. It must be called after fs has been parsed,
+// and leaves alone the flags that were set on the command line, so
+// that those override the environment entirely; for flags that may
+// be repeated, the values from the command line replace those from
+// the environment rather than adding to them.
+func setFlagsFromEnv(fs *flag.FlagSet, lookupEnv func(string) (string, bool)) {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	fs.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] {
+			return
+		}
+		name := "GOTESTDEPS_" + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if v, ok := lookupEnv(name); ok {
+			if err := f.Value.Set(v); err != nil {
+				log.Fatalf("invalid value %q for $%s: %v", v, name, err)
+			}
+		}
+	})
+}
+
+// loadGraph loads the packages matching patterns in the given directory
+// (or the current directory if it is empty) both with and without tests
+// and derives the module graph from them. It also returns the
+// packages from the test-inclusive load.
+func loadGraph(dir string, patterns []string) (*graph, []*packages.Package) {
 	// 1. Load the module universe twice: with and without test files.
-	mainMod, _, noTestMods := loadModuleSet(false, "all")
-	_, testPkgs, withTestMods := loadModuleSet(true, "all")
+	mainMod, noTestPkgs, noTestMods := loadModuleSet(dir, false, patterns...)
+	_, testPkgs, withTestMods := loadModuleSet(dir, true, patterns...)
+	if mainMod == "" {
+		// Without a main module nothing can be colored as such,
+		// which is usually because of running outside a module.
+		if *flagStrict {
+			log.Fatalf("no main module found; run inside a module")
+		}
+		log.Printf("warning: no main module found; run inside a module")
+	}
+
+	if *flagDeepTests {
+		deepPkgs, deepMods := loadDeepTests(dir, mainMod, withTestMods)
+		testPkgs = append(testPkgs, deepPkgs...)
+		for m := range deepMods {
+			withTestMods[m] = struct{}{}
+		}
+	}
+
+	if *flagMainTestsAsProd {
+		// Count everything used by the main module's own tests
+		// as if it were used by production code, so that only
+		// modules introduced by the tests of dependencies remain
+		// test-only. Note that the "all" pattern already includes
+		// the packages imported by the main module's tests, so
+		// this makes a difference only for other patterns.
+		var mainTests []*packages.Package
+		for _, p := range testPkgs {
+			if p.Module != nil && p.Module.Main && isTestVariant(p) {
+				mainTests = append(mainTests, p)
+			}
+		}
+		traverse(mainTests, func(p *packages.Package) {
+			if n := nodeOf(p); n != "" {
+				noTestMods[n] = struct{}{}
+			}
+		})
+	}
 
 	// 2. Any module present only in the second load is “test-only”.
 	testOnly := difference(withTestMods, noTestMods)
+	if *flagTestOnlyMode == "direct" {
+		// Only modules imported by test files themselves count
+		// as test-only; anything they in turn depend on counts
+		// as a regular dependency.
+		testOnly = intersection(testOnly, directTestModules(testPkgs))
+	}
 
 	// 3. Derive module-to-module edges from the test-inclusive graph.
-	edges, nodes := buildEdges(testPkgs)
+	start := time.Now()
+	edges, nodes, counts := buildEdges(testPkgs)
+	prodEdges, _, _ := buildEdges(noTestPkgs)
+	tracef("buildEdges: %d modules, %d edges, in %v", len(nodes), len(counts), time.Since(start))
+
+	// Ensure pure test nodes without outgoing edges still appear.
+	for m := range testOnly {
+		nodes[m] = struct{}{}
+	}
+
+	testOnlyPkgs := make(map[string]struct{})
+	if *flagGranularity == "package" {
+		testOnlyPkgs = testOnlyPackages(noTestPkgs, testPkgs)
+	}
+
+	versions := make(map[string]string)
+	pkgCounts := make(map[string]int)
+	replaces := make(map[string]string)
+	localReplaces := make(map[string]struct{})
+	deprecated := make(map[string]string)
+	dirs := make(map[string]string)
+	retracted := make(map[string]string)
+	if *flagRetractions {
+		var err error
+		if retracted, err = retractedVersions(dir); err != nil {
+			log.Printf("cannot check for retractions: %v", err)
+			retracted = make(map[string]string)
+		}
+	}
+	seenMods := make(map[string]bool)
+	// nodeMods holds the module that each node belongs to. The two
+	// differ only with -granularity package, when the facts about a
+	// module apply to each of its package nodes.
+	nodeMods := make(map[string]string)
+	traverse(testPkgs, func(p *packages.Package) {
+		if p.Module != nil {
+			if p.Module.Dir != "" {
+				dirs[p.Module.Path] = p.Module.Dir
+			}
+			if *flagDeprecations && !seenMods[p.Module.Path] {
+				seenMods[p.Module.Path] = true
+				if msg := deprecation(readModFile(p.Module)); msg != "" {
+					deprecated[p.Module.Path] = msg
+				}
+			}
+			n := nodeOf(p)
+			if n == "" {
+				return
+			}
+			nodeMods[n] = p.Module.Path
+			versions[n] = p.Module.Version
+			if r := p.Module.Replace; r != nil {
+				replaces[n] = r.Path
+				if r.Version == "" {
+					localReplaces[n] = struct{}{}
+				}
+			}
+			if !isTestVariant(p) {
+				pkgCounts[n]++
+			}
+		}
+	})
+	if *flagGranularity == "package" {
+		deprecated = byNode(deprecated, nodeMods)
+		retracted = byNode(retracted, nodeMods)
+	}
+
+	return &graph{
+		mainMod:    mainMod,
+		nodes:      nodes,
+		edges:      edges,
+		testOnly:   testOnly,
+		versions:   versions,
+		pkgCounts:  pkgCounts,
+		replaces:   replaces,
+		prodEdges:  prodEdges,
+		conflicts:  findConflicts(testPkgs),
+		loadErrors: loadErrors(append(noTestPkgs, testPkgs...)),
+		mainUsage:  mainUsage(noTestPkgs),
+		broken:     brokenModules(testPkgs),
+		deprecated: deprecated,
+		dirs:       dirs,
+		retracted:  retracted,
+		tooltips:   make(map[string]string),
+		counts:     counts,
+		edgeStyles: make(map[edge]string),
+		edgeLabels: make(map[edge]string),
+		notes:      make(map[string][]string),
+
+		localReplaces: localReplaces,
+		testOnlyPkgs:  testOnlyPkgs,
+	}, testPkgs
+}
+
+// byNode returns the values in m, which is keyed by module path,
+// keyed instead by each node in nodeMods that belongs to the module.
+func byNode(m, nodeMods map[string]string) map[string]string {
+	byNode := make(map[string]string)
+	for n, mod := range nodeMods {
+		if v, ok := m[mod]; ok {
+			byNode[n] = v
+		}
+	}
+	return byNode
+}
+
+// loadErrors returns the distinct errors reported for
+// any of the packages in pkgs or their dependencies, sorted.
+func loadErrors(pkgs []*packages.Package) []string {
+	seen := make(map[string]struct{})
+	traverse(pkgs, func(p *packages.Package) {
+		for _, err := range p.Errors {
+			seen[err.Error()] = struct{}{}
+		}
+	})
+	return sortedKeys(seen)
+}
+
+// loadPackages is like packages.Load but also traces
+// the configuration and duration of the load.
+func loadPackages(phase string, cfg *packages.Config, patterns []string) ([]*packages.Package, error) {
+	tracef("%s: patterns %q", phase, patterns)
+	tracef("%s: mode %v, tests %v, build flags %q, env overrides %q", phase, cfg.Mode, cfg.Tests, cfg.BuildFlags, loadEnv())
+	start := time.Now()
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	if *flagTrace {
+		total := 0
+		traverse(pkgs, func(*packages.Package) {
+			total++
+		})
+		tracef("%s: %d root packages, %d in total, in %v", phase, len(pkgs), total, time.Since(start))
+	}
+	return pkgs, nil
+}
+
+// tracef logs a message if -trace is enabled.
+func tracef(format string, args ...any) {
+	if *flagTrace {
+		log.Printf("trace: "+format, args...)
+	}
+}
+
+// newConfig returns the configuration used for loading packages
+// in the given directory.
+func newConfig(dir string, includeTests bool) *packages.Config {
+	cfg := &packages.Config{
+		Dir:   dir,
+		Mode:  packages.NeedName | packages.NeedImports | packages.NeedModule | packages.NeedDeps | packages.NeedFiles,
+		Tests: includeTests,
+	}
+	if env := loadEnv(); len(env) > 0 {
+		cfg.Env = append(os.Environ(), env...)
+	}
+	return cfg
+}
 
-	// Ensure pure test nodes without outgoing edges still appear.
-	for m := range testOnly {
-		nodes[m] = struct{}{}
+// loadEnv returns the environment variables to set
+// in addition to the usual environment when loading packages.
+func loadEnv() []string {
+	var env []string
+	if *flagGOOS != "" {
+		env = append(env, "GOOS="+*flagGOOS)
+	}
+	if *flagGOARCH != "" {
+		env = append(env, "GOARCH="+*flagGOARCH)
 	}
+	if *flagOffline {
+		// Resolve modules from the module cache only, so that
+		// a missing module fails quickly instead of waiting
+		// on the network.
+		env = append(env,
+			"GOPROXY=off",
+			"GOFLAGS="+strings.TrimSpace(os.Getenv("GOFLAGS")+" -mod=mod"),
+		)
+	}
+	return env
+}
 
-	// 4. Emit GraphViz.
-	writeDot(os.Stdout, mainMod, edges, nodes, testOnly)
+// checkPlatform checks that the platform selected with -goos and -goarch
+// is one that the go command knows about. The check is skipped if the
+// list of known platforms is unavailable.
+func checkPlatform() {
+	if *flagGOOS == "" && *flagGOARCH == "" {
+		return
+	}
+	out, err := exec.Command("go", "tool", "dist", "list").Output()
+	if err != nil {
+		log.Printf("cannot check platform: %v", err)
+		return
+	}
+	for _, platform := range strings.Fields(string(out)) {
+		goos, goarch, _ := strings.Cut(platform, "/")
+		if (*flagGOOS == "" || *flagGOOS == goos) && (*flagGOARCH == "" || *flagGOARCH == goarch) {
+			return
+		}
+	}
+	log.Fatalf("unsupported platform GOOS=%q GOARCH=%q (see go tool dist list)", *flagGOOS, *flagGOARCH)
 }
 
-func loadModuleSet(includeTests bool, pattern string) (string, []*packages.Package, map[string]struct{}) {
-	cfg := &packages.Config{
-		Mode:  packages.NeedImports | packages.NeedModule | packages.NeedDeps,
-		Tests: includeTests,
+func loadModuleSet(dir string, includeTests bool, patterns ...string) (string, []*packages.Package, map[string]struct{}) {
+	phase := "load-no-tests"
+	if includeTests {
+		phase = "load-tests"
 	}
-	pkgs, err := packages.Load(cfg, pattern)
+	pkgs, err := loadPackages(phase, newConfig(dir, includeTests), patterns)
 	if err != nil {
 		log.Fatalf("packages.Load (Tests=%v): %v", includeTests, err)
 	}
-	if packages.PrintErrors(pkgs) > 0 {
+	if *flagBroken {
+		// Errors are expected when looking for broken
+		// modules, so report them without giving up.
+		packages.PrintErrors(pkgs)
+	} else if !*flagQuietErrors && packages.PrintErrors(pkgs) > 0 {
+		if *flagOffline {
+			log.Printf("note: with -offline, modules that are not in the module cache cannot be loaded")
+		}
 		log.Fatal("aborting due to previous errors")
 	}
+	if includeTests && *flagIgnoreExamples {
+		pkgs = dropExampleTests(pkgs)
+	}
+	if includeTests && ignoredTestFiles != nil {
+		pkgs = dropIgnoredTestImports(pkgs, ignoredTestFiles)
+	}
 
 	mods := make(map[string]struct{})
 	mainMod := ""
 	traverse(pkgs, func(p *packages.Package) {
-		if p.Module != nil {
-			mods[p.Module.Path] = struct{}{}
-			if p.Module.Main {
-				mainMod = p.Module.Path
-			}
+		if n := nodeOf(p); n != "" {
+			mods[n] = struct{}{}
+		}
+		if p.Module != nil && p.Module.Main {
+			mainMod = p.Module.Path
 		}
 	})
 	return mainMod, pkgs, mods
 }
 
+// checkCaseCollisions reports any module paths in g that differ only by case.
+// Such paths are easily confused and can indicate a typo or an attempt
+// at impersonating a well-known module.
+func checkCaseCollisions(g *graph) {
+	byLower := make(map[string][]string)
+	for _, n := range sortedKeys(g.nodes) {
+		lower := strings.ToLower(n)
+		byLower[lower] = append(byLower[lower], n)
+	}
+	collisions := 0
+	for _, lower := range sortedKeys(byLower) {
+		if paths := byLower[lower]; len(paths) > 1 {
+			log.Printf("warning: module paths differ only in case: %s", strings.Join(paths, ", "))
+			collisions++
+		}
+	}
+	if collisions > 0 && *flagStrict {
+		log.Fatalf("found %d sets of module paths that differ only in case", collisions)
+	}
+}
+
+// loadDeepTests loads all the packages in each of the given modules
+// other than the main module, including their tests, and returns them
+// along with the set of modules they use. Dependency modules often have
+// packages that cannot be built in the context of the main module,
+// so packages with errors are counted but otherwise ignored.
+func loadDeepTests(dir, mainMod string, mods map[string]struct{}) ([]*packages.Package, map[string]struct{}) {
+	var patterns []string
+	for _, m := range sortedKeys(mods) {
+		if m != mainMod {
+			patterns = append(patterns, m+"/...")
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	log.Printf("-deep-tests: loading all packages and tests of %d dependency modules; this may take a while", len(patterns))
+	pkgs, err := loadPackages("load-deep-tests", newConfig(dir, true), patterns)
+	if err != nil {
+		log.Fatalf("packages.Load (deep tests): %v", err)
+	}
+	if *flagIgnoreExamples {
+		pkgs = dropExampleTests(pkgs)
+	}
+	deepMods := make(map[string]struct{})
+	broken := 0
+	traverse(pkgs, func(p *packages.Package) {
+		if len(p.Errors) > 0 {
+			broken++
+		}
+		if p.Module != nil {
+			deepMods[p.Module.Path] = struct{}{}
+		}
+	})
+	if broken > 0 {
+		log.Printf("-deep-tests: ignoring errors in %d packages", broken)
+	}
+	return pkgs, deepMods
+}
+
+// isTestVariant reports whether p exists only because tests were loaded:
+// a package augmented with its test files, an external _test package
+// or a generated test main package.
+func isTestVariant(p *packages.Package) bool {
+	return p.ID != p.PkgPath || strings.HasSuffix(p.PkgPath, "_test") || isTestMain(p)
+}
+
+// isTestMain reports whether p is a generated test main package.
+func isTestMain(p *packages.Package) bool {
+	return strings.HasSuffix(p.PkgPath, ".test")
+}
+
+// loadOptions returns a description of the settings that
+// affect the result of loading packages, for use as a cache key.
+func loadOptions() []string {
+	return []string{
+		fmt.Sprintf("deep-tests=%v", *flagDeepTests),
+		fmt.Sprintf("count-main-tests-as-prod=%v", *flagMainTestsAsProd),
+		fmt.Sprintf("ignore-examples=%v", *flagIgnoreExamples),
+		fmt.Sprintf("ignore-test-files=%q", *flagIgnoreTestFiles),
+		fmt.Sprintf("test-only-mode=%v", *flagTestOnlyMode),
+		fmt.Sprintf("granularity=%v", *flagGranularity),
+		fmt.Sprintf("include-stdlib=%v", *flagIncludeStdlib),
+		fmt.Sprintf("collapse-std=%v", *flagCollapseStd),
+		fmt.Sprintf("collapse-module=%q", flagCollapseModules),
+		fmt.Sprintf("hide-main-packages=%v", *flagHideMainPkgs),
+		fmt.Sprintf("deprecations=%v", *flagDeprecations),
+		fmt.Sprintf("retractions=%v", *flagRetractions),
+		fmt.Sprintf("env=%q", loadEnv()),
+	}
+}
+
 // traverse walks the import graph once, visiting every package exactly once.
 func traverse(roots []*packages.Package, visit func(*packages.Package)) {
 	seen := make(map[*packages.Package]bool)
@@ -102,28 +1616,108 @@ func traverse(roots []*packages.Package, visit func(*packages.Package)) {
 	}
 }
 
-func buildEdges(pkgs []*packages.Package) (map[string]map[string]struct{}, map[string]struct{}) {
-	edges := make(map[string]map[string]struct{})
-	nodes := make(map[string]struct{})
+// buildEdges returns the edges between the nodes of the packages
+// reachable from pkgs, the nodes themselves, and the number of distinct
+// package imports that contribute to each edge. The packages are
+// divided between several workers, whose results are merged, so the
+// result does not depend on how the work was scheduled.
+func buildEdges(pkgs []*packages.Package) (map[string]map[string]struct{}, map[string]struct{}, map[edge]int) {
+	var all []*packages.Package
 	traverse(pkgs, func(p *packages.Package) {
-		from := modulePathOf(p)
-		if from == "" {
-			return // stdlib
+		all = append(all, p)
+	})
+
+	// Test variants share a PkgPath with the package they augment,
+	// and each importing package pair must be counted only once, so
+	// all the packages with the same path go to the same worker.
+	// The workers then never see the same pair and their counts
+	// can simply be added together.
+	workers := min(runtime.GOMAXPROCS(0), len(all)/100+1)
+	shards := make([][]*packages.Package, workers)
+	shardOf := make(map[string]int)
+	for _, p := range all {
+		w, ok := shardOf[p.PkgPath]
+		if !ok {
+			w = len(shardOf) % workers
+			shardOf[p.PkgPath] = w
 		}
-		nodes[from] = struct{}{}
-		for _, imp := range p.Imports {
-			to := modulePathOf(imp)
-			if to == "" || to == from {
-				continue
-			}
-			if edges[from] == nil {
-				edges[from] = make(map[string]struct{})
+		shards[w] = append(shards[w], p)
+	}
+
+	edges := make(map[string]map[string]struct{})
+	nodes := make(map[string]struct{})
+	counts := make(map[edge]int)
+	var mu sync.Mutex
+	merge := func(localNodes map[string]struct{}, localCounts map[edge]int) {
+		mu.Lock()
+		defer mu.Unlock()
+		maps.Copy(nodes, localNodes)
+		for e, n := range localCounts {
+			counts[e] += n
+			if edges[e.from] == nil {
+				edges[e.from] = make(map[string]struct{})
 			}
-			edges[from][to] = struct{}{}
-			nodes[to] = struct{}{}
+			edges[e.from][e.to] = struct{}{}
 		}
-	})
-	return edges, nodes
+	}
+
+	var wg sync.WaitGroup
+	for _, shard := range shards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			localNodes := make(map[string]struct{})
+			localCounts := make(map[edge]int)
+			seenImports := make(map[edge]bool)
+			for _, p := range shard {
+				from := nodeOf(p)
+				if from == "" {
+					continue // stdlib
+				}
+				localNodes[from] = struct{}{}
+				for _, imp := range p.Imports {
+					to := nodeOf(imp)
+					if to == "" || to == from {
+						continue
+					}
+					localNodes[to] = struct{}{}
+					if pair := (edge{p.PkgPath, imp.PkgPath}); !seenImports[pair] {
+						seenImports[pair] = true
+						localCounts[edge{from, to}]++
+					}
+				}
+			}
+			merge(localNodes, localCounts)
+		}()
+	}
+	wg.Wait()
+	return edges, nodes, counts
+}
+
+// nodeOf returns the name of the graph node that p belongs to, or the
+// empty string if p is not represented in the graph. This is p's module
+// path unless -granularity package has been given, in which case it is
+// the path of the package itself, with external test packages treated
+// as part of the package that they test. The packages of any module
+// named by -collapse-module, or of the main module with -hide-main-packages,
+// share a single node named after the module.
+func nodeOf(p *packages.Package) string {
+	if *flagGranularity != "package" {
+		return modulePathOf(p)
+	}
+	switch {
+	case p == nil || isTestMain(p):
+		return ""
+	case p.Module != nil && (flagCollapseModules.contains(p.Module.Path) || p.Module.Main && *flagHideMainPkgs):
+		return p.Module.Path
+	case p.Module != nil:
+		return strings.TrimSuffix(p.PkgPath, "_test")
+	case !*flagIncludeStdlib:
+		return ""
+	case *flagCollapseStd:
+		return "std"
+	}
+	return p.PkgPath
 }
 
 func modulePathOf(p *packages.Package) string {
@@ -133,10 +1727,62 @@ func modulePathOf(p *packages.Package) string {
 	return ""
 }
 
-func writeDot(out io.Writer, mainMod string, edges map[string]map[string]struct{},
-	nodes, testOnly map[string]struct{}) {
-
+// writeDot writes g as a mermaid diagram inside a markdown code block.
+func writeDot(out io.Writer, g *graph) {
+	// Large graphs produce many small writes, so buffer
+	// them rather than going to the destination for each.
+	bw := bufio.NewWriter(out)
+	defer func() {
+		if err := bw.Flush(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+	out = bw
+	if *flagEdgesOnly {
+		writeEdgesOnly(out, g)
+		return
+	}
 	fmt.Fprintf(out, "```mermaid\n")
+	writeMermaid(out, g)
+	fmt.Fprintf(out, "```\n")
+	if *flagLegendInline {
+		writeMarkdownLegend(out, g)
+	}
+}
+
+// writeEdgesOnly writes just the mermaid edge lines of g, for
+// appending to a diagram whose nodes are declared and styled
+// elsewhere. The node identifiers are those that writeMermaid
+// would use; the mapping from each identifier to its module is
+// printed to standard error so that it can be correlated.
+func writeEdgesOnly(out io.Writer, g *graph) {
+	indent := "    "
+	if *flagMinify {
+		indent = ""
+	}
+	allNodes := g.nodeOrder()
+	ids := nodeIDs(allNodes)
+	for _, name := range allNodes {
+		fmt.Fprintf(os.Stderr, "%s\t%s\n", ids[name], name)
+	}
+	for _, f := range sortedKeys(g.edges) {
+		for _, t := range g.successors(f) {
+			fmt.Fprintf(out, "%s%s %s %s\n", indent, ids[f], g.arrow(f, t), ids[t])
+		}
+	}
+}
+
+// writeMermaid writes g as mermaid flowchart source.
+func writeMermaid(out io.Writer, g *graph) {
+	edges := g.edges
+	indent := "    "
+	if *flagMinify {
+		indent = ""
+	}
+
+	if init := mermaidInit(); init != "" {
+		fmt.Fprintf(out, "%%%%{init: %s}%%%%\n", init)
+	}
 	fmt.Fprintf(out, "graph LR\n")
 	//	fmt.Fprint(out, `
 	//digraph G {
@@ -149,17 +1795,34 @@ func writeDot(out io.Writer, mainMod string, edges map[string]map[string]struct{
 	//    quantum="0.5";
 	//`)
 	// Deterministic ordering.
-	allNodes := make([]string, 0, len(nodes))
-	for n := range nodes {
-		allNodes = append(allNodes, n)
+	allNodes := g.nodeOrder()
+	ids := nodeIDs(allNodes)
+	inCluster := make(map[string]bool)
+	for _, c := range g.clusters {
+		for n := range c.nodes {
+			inCluster[n] = true
+		}
 	}
-	sort.Strings(allNodes)
-	indexes := make(map[string]int)
-	for i, name := range allNodes {
-		indexes[name] = i
+	for _, name := range allNodes {
+		if !inCluster[name] {
+			open, close := g.shape(name)
+			fmt.Fprintf(out, "%s%s%s%s%s\n", indent, ids[name], open, mermaidQuote(g.label(name)), close)
+		}
+	}
+	for ci, c := range g.clusters {
+		fmt.Fprintf(out, "%ssubgraph C%d[%s]\n", indent, ci, mermaidQuote(c.title))
+		for _, name := range allNodes {
+			if _, ok := c.nodes[name]; ok {
+				open, close := g.shape(name)
+				fmt.Fprintf(out, "%s%s%s%s%s%s\n", indent, indent, ids[name], open, mermaidQuote(g.label(name)), close)
+			}
+		}
+		fmt.Fprintf(out, "%send\n", indent)
 	}
-	for i, name := range allNodes {
-		fmt.Fprintf(out, "    N%d[%q]\n", i, name)
+	for _, name := range allNodes {
+		if tip := g.tooltips[name]; tip != "" {
+			fmt.Fprintf(out, "%sclick %s href \"https://pkg.go.dev/%s\" \"%s\"\n", indent, ids[name], name, tooltipEscaper.Replace(tip))
+		}
 	}
 
 	froms := make([]string, 0, len(edges))
@@ -168,41 +1831,557 @@ func writeDot(out io.Writer, mainMod string, edges map[string]map[string]struct{
 	}
 	sort.Strings(froms)
 
+	// Mermaid identifies links by the order in which they
+	// are declared, so record the index of each styled edge.
+	linkStyles := make(map[string][]string)
+	nlinks := 0
 	for _, f := range froms {
-		tos := make([]string, 0, len(edges[f]))
-		for t := range edges[f] {
-			tos = append(tos, t)
+		for _, t := range g.successors(f) {
+			arrow := g.arrow(f, t)
+			style := g.edgeStyles[edge{f, t}]
+			if _, mutual := edges[t][f]; mutual && *flagMergeBidi {
+				if t < f {
+					continue // drawn along with the edge from t to f
+				}
+				arrow = "<" + arrow
+				if style == "" {
+					style = g.edgeStyles[edge{t, f}]
+				}
+			}
+			if label := g.edgeLabels[edge{f, t}]; label != "" {
+				fmt.Fprintf(out, "%s%s %s|%s| %s\n", indent, ids[f], arrow, mermaidQuote(label), ids[t])
+			} else {
+				fmt.Fprintf(out, "%s%s %s %s\n", indent, ids[f], arrow, ids[t])
+			}
+			if style != "" {
+				linkStyles[style] = append(linkStyles[style], fmt.Sprint(nlinks))
+			}
+			nlinks++
 		}
-		sort.Strings(tos)
-		for _, t := range tos {
-			fmt.Fprintf(out, "    N%d --> N%d\n", indexes[f], indexes[t])
+	}
+	for _, e := range g.extraEdges {
+		from, fromOK := ids[e.from]
+		to, toOK := ids[e.to]
+		if fromOK && toOK {
+			fmt.Fprintf(out, "%s%s -.->|%s| %s\n", indent, from, mermaidQuote(e.label), to)
 		}
 	}
-	nodeColor := func(className, color string, choose func(name string) bool) {
-		var selected []string
-		for i, name := range allNodes {
-			if choose(name) {
-				selected = append(selected, fmt.Sprintf("N%d", i))
-			}
+	if g.noDeps {
+		fmt.Fprintf(out, "%s%%%% no external module dependencies\n", indent)
+	}
+	if len(g.hidden) > 0 {
+		fmt.Fprintf(out, "%s%%%% hidden ubiquitous deps: %s\n", indent, strings.Join(g.hidden, ", "))
+	}
+	if len(g.loadErrors) > 0 && *flagQuietErrors {
+		fmt.Fprintf(out, "%s%%%% errors:\n", indent)
+		for _, e := range g.loadErrors {
+			fmt.Fprintf(out, "%s%%%% %s\n", indent, strings.ReplaceAll(e, "\n", " "))
 		}
+	}
+	for _, style := range sortedKeys(linkStyles) {
+		fmt.Fprintf(out, "%slinkStyle %s %s;\n", indent, strings.Join(linkStyles[style], ","), style)
+	}
+	defineClass := func(className, style string, selected []string) {
 		if len(selected) == 0 {
 			return
 		}
-		fmt.Fprintf(out, "    classDef %s fill:%s,stroke:#333,stroke-width:1px;\n", className, color)
-		fmt.Fprintf(out, "    class %s %s;\n", strings.Join(selected, ","), className)
+		if *flagInlineStyles {
+			for _, id := range selected {
+				fmt.Fprintf(out, "%sstyle %s %s;\n", indent, id, style)
+			}
+			return
+		}
+		fmt.Fprintf(out, "%sclassDef %s %s;\n", indent, className, style)
+		fmt.Fprintf(out, "%sclass %s %s;\n", indent, strings.Join(selected, ","), className)
 	}
-	nodeColor("mainModule", mainColor, func(name string) bool {
-		return name == mainMod
-	})
-	nodeColor("testOnlyDep", testColor, func(name string) bool {
-		_, isTestOnly := testOnly[name]
-		return isTestOnly && name != mainMod
-	})
-	nodeColor("regularDep", nonTestColor, func(name string) bool {
-		_, isTestOnly := testOnly[name]
-		return !isTestOnly && name != mainMod
-	})
-	fmt.Fprintf(out, "```\n")
+	members := g.classMembers(allNodes)
+	for _, c := range g.classes() {
+		var selected []string
+		for _, name := range members[c] {
+			selected = append(selected, ids[name])
+		}
+		defineClass(c.name, fmt.Sprintf("fill:%s,stroke:#333,stroke-width:1px", c.color), selected)
+	}
+	for _, o := range g.overlays {
+		var selected []string
+		for _, name := range allNodes {
+			if _, ok := o.nodes[name]; ok {
+				selected = append(selected, ids[name])
+			}
+		}
+		defineClass(o.name, o.style, selected)
+	}
+}
+
+// themeVariables holds the variables read from -theme-file, if any.
+var themeVariables map[string]any
+
+// mermaidInit returns the JSON configuration for a mermaid
+// init directive, or the empty string if none is needed.
+func mermaidInit() string {
+	config := make(map[string]any)
+	if *flagRenderer != "" {
+		config["flowchart"] = map[string]string{
+			"defaultRenderer": *flagRenderer,
+		}
+	}
+	if themeVariables != nil {
+		// Mermaid applies theme variables only to the base theme.
+		config["theme"] = "base"
+		config["themeVariables"] = themeVariables
+	}
+	if len(config) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
+
+// classShapes holds the opening and closing brackets of
+// the mermaid node shape used for each built-in class.
+var classShapes = map[string][2]string{
+	"mainModule":  {"([", "])"},
+	"testOnlyDep": {"{{", "}}"},
+}
+
+// shape returns the brackets to put around the label of the given
+// node in mermaid source: a rectangle unless -shapes is in effect
+// and the node's class has a shape of its own.
+func (g *graph) shape(name string) (open, close string) {
+	if s, ok := classShapes[g.class(name)]; ok && *flagShapes {
+		return s[0], s[1]
+	}
+	return "[", "]"
+}
+
+// nodeOrder returns the nodes of g in the order in which they
+// are declared and numbered: sorted by name, but with the test-only
+// nodes first when -test-first is in effect.
+func (g *graph) nodeOrder() []string {
+	nodes := sortedKeys(g.nodes)
+	if *flagTestFirst {
+		sort.SliceStable(nodes, func(i, j int) bool {
+			_, ti := g.testOnly[nodes[i]]
+			_, tj := g.testOnly[nodes[j]]
+			return ti && !tj
+		})
+	}
+	return nodes
+}
+
+// edgeArrows holds the mermaid arrow used for production
+// edges and for edges found only when tests are loaded,
+// as selected by the -edge-style flag.
+var edgeArrows = struct{ prod, test string }{"-->", "-->"}
+
+// mermaidArrows maps each -edge-style name to its mermaid arrow.
+var mermaidArrows = map[string]string{
+	"solid":  "-->",
+	"dotted": "-.->",
+	"thick":  "==>",
+}
+
+// parseEdgeStyle sets edgeArrows from the value of the -edge-style
+// flag, which is either a single style applied to every edge or
+// a comma-separated list of prod=style and test=style settings.
+func parseEdgeStyle(s string) error {
+	if arrow, ok := mermaidArrows[s]; ok {
+		edgeArrows.prod, edgeArrows.test = arrow, arrow
+		return nil
+	}
+	for _, f := range strings.Split(s, ",") {
+		kind, style, ok := strings.Cut(f, "=")
+		arrow, known := mermaidArrows[style]
+		if !ok || !known {
+			return fmt.Errorf("%q is not of the form kind=style with style one of solid, dotted or thick", f)
+		}
+		switch kind {
+		case "prod":
+			edgeArrows.prod = arrow
+		case "test":
+			edgeArrows.test = arrow
+		default:
+			return fmt.Errorf("unknown kind of edge %q (want prod or test)", kind)
+		}
+	}
+	return nil
+}
+
+// arrow returns the mermaid arrow to use for the edge from f to t.
+func (g *graph) arrow(f, t string) string {
+	if _, ok := g.prodEdges[f][t]; ok {
+		return edgeArrows.prod
+	}
+	return edgeArrows.test
+}
+
+// successors returns the targets of the edges from the given node,
+// in the order selected by the -sort-edges flag.
+func (g *graph) successors(name string) []string {
+	tos := sortedKeys(g.edges[name])
+	if *flagSortEdges == "count" {
+		sort.SliceStable(tos, func(i, j int) bool {
+			return g.counts[edge{name, tos[i]}] > g.counts[edge{name, tos[j]}]
+		})
+	}
+	return tos
+}
+
+// addTooltip adds the text s to the tooltip of the given node.
+func (g *graph) addTooltip(name, s string) {
+	if tip := g.tooltips[name]; tip == s {
+		return
+	} else if tip != "" {
+		s = tip + "; " + s
+	}
+	g.tooltips[name] = s
+}
+
+// label returns the text shown for the given node.
+func (g *graph) label(name string) string {
+	notes := g.notes[name]
+	if short, ok := g.shortNames[name]; ok {
+		name = short
+	}
+	if trimmed, ok := strings.CutPrefix(name, *flagTrimPrefix); ok && trimmed != "" && *flagTrimPrefix != "" {
+		name = trimmed
+	}
+	if *flagStripMajor {
+		if prefix, major, ok := module.SplitPathVersion(name); ok && major != "" {
+			name = prefix
+			notes = append([]string{"(" + strings.TrimLeft(major, "/.") + ")"}, notes...)
+		}
+	}
+	if *flagMaxLabelLen > 0 {
+		name = truncateMiddle(name, *flagMaxLabelLen)
+	}
+	if len(notes) == 0 {
+		return name
+	}
+	return name + " " + strings.Join(notes, " ")
+}
+
+// truncateMiddle shortens the module path s to at most n characters by
+// replacing part of its middle with an ellipsis, keeping the first and
+// last path elements, which are usually the most informative. If those
+// alone do not fit, the end of s is truncated instead.
+func truncateMiddle(s string, n int) string {
+	r := []rune(s)
+	switch {
+	case len(r) <= n:
+		return s
+	case n <= 0:
+		// There is no room even for the ellipsis.
+		return ""
+	}
+	first, last := len(r), 0
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		first = len([]rune(s[:i]))
+		last = len([]rune(s[strings.LastIndexByte(s, '/'):]))
+	}
+	if keep := n - 1 - last; keep >= first && last > 0 {
+		return string(r[:keep]) + "…" + string(r[len(r)-last:])
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// replaceEdgeStyle is the link style used for edges from
+// replaced modules to their replacements.
+const replaceEdgeStyle = "stroke:#999,stroke-dasharray:4 4"
+
+// inDegrees returns the number of edges leading to each node of g.
+func (g *graph) inDegrees() map[string]int {
+	inDegree := make(map[string]int)
+	for _, tos := range g.edges {
+		for to := range tos {
+			inDegree[to]++
+		}
+	}
+	return inDegree
+}
+
+// hideUbiquitous removes the nodes of g other than the main module
+// that have at least minIn incoming edges, recording them in g.hidden.
+func (g *graph) hideUbiquitous(minIn int) {
+	inDegree := g.inDegrees()
+	keep := make(map[string]struct{})
+	for n := range g.nodes {
+		if inDegree[n] >= minIn && n != g.mainMod {
+			g.hidden = append(g.hidden, n)
+		} else {
+			keep[n] = struct{}{}
+		}
+	}
+	sort.Strings(g.hidden)
+	g.keepNodes(keep)
+}
+
+// commonEdgeStyle is the link style used to de-emphasize
+// edges into modules that many other modules depend on.
+const commonEdgeStyle = "stroke:#dddddd,stroke-width:1px"
+
+// fadeCommonEdges styles the edges into each module with at least
+// minIn incoming edges faintly, so that ubiquitous utility modules
+// do not dominate the picture. Edges that already have a style
+// are left alone.
+func (g *graph) fadeCommonEdges(minIn int) {
+	inDegree := g.inDegrees()
+	for from, tos := range g.edges {
+		for to := range tos {
+			e := edge{from, to}
+			if inDegree[to] >= minIn && g.edgeStyles[e] == "" {
+				g.edgeStyles[e] = commonEdgeStyle
+			}
+		}
+	}
+}
+
+// addReplaceEdges adds a node for the replacement of each replaced
+// module in g, with a distinctively styled edge leading to it from
+// the module that it replaces.
+func (g *graph) addReplaceEdges() {
+	for from, to := range g.replaces {
+		if _, ok := g.nodes[from]; !ok || from == to {
+			continue
+		}
+		g.nodes[to] = struct{}{}
+		if g.edges[from] == nil {
+			g.edges[from] = make(map[string]struct{})
+		}
+		g.edges[from][to] = struct{}{}
+		g.edgeStyles[edge{from, to}] = replaceEdgeStyle
+		if _, ok := g.testOnly[from]; !ok {
+			if g.prodEdges[from] == nil {
+				g.prodEdges[from] = make(map[string]struct{})
+			}
+			g.prodEdges[from][to] = struct{}{}
+		}
+	}
+}
+
+// localReplaceStyle is the mermaid style used for modules
+// replaced by a local directory.
+const localReplaceStyle = "fill:#ffff33,stroke:#c90,stroke-width:2px"
+
+// pseudoVersionStyle is the mermaid style used for
+// modules whose selected version is a pseudo-version.
+const pseudoVersionStyle = "stroke:#06c,stroke-width:2px,stroke-dasharray:2 2"
+
+// pseudoVersions returns the set of modules in g whose selected
+// version is a pseudo-version, identifying an untagged commit.
+func (g *graph) pseudoVersions() map[string]struct{} {
+	pseudo := make(map[string]struct{})
+	for n := range g.nodes {
+		if module.IsPseudoVersion(g.versions[n]) {
+			pseudo[n] = struct{}{}
+		}
+	}
+	return pseudo
+}
+
+// outdatedStyle is the mermaid style used for modules
+// with versions below the one given by -version-below.
+const outdatedStyle = "fill:#ffe4b5,stroke:#c60,stroke-width:2px"
+
+// versionsBelow returns the set of modules in g whose selected
+// version is lower than the given semantic version. Pseudo-versions
+// compare as prereleases of the version they are based on, and
+// +incompatible suffixes are ignored as build metadata.
+func (g *graph) versionsBelow(v string) map[string]struct{} {
+	below := make(map[string]struct{})
+	for n := range g.nodes {
+		if mv := g.versions[n]; semver.IsValid(mv) && semver.Compare(mv, v) < 0 {
+			below[n] = struct{}{}
+		}
+	}
+	return below
+}
+
+// shortVersion returns an abbreviated form of the version v for use in
+// labels. Pseudo-versions lose their timestamp and have their commit
+// hash shortened, so v0.0.0-20231201120000-abcdef123456 becomes
+// v0.0.0-abcdef1, and any +incompatible suffix is removed.
+func shortVersion(v string) string {
+	v = strings.TrimSuffix(v, "+incompatible")
+	if !module.IsPseudoVersion(v) {
+		return v
+	}
+	rev, err := module.PseudoVersionRev(v)
+	if err != nil {
+		return v
+	}
+	// The revision is preceded by a 14-digit timestamp and a hyphen.
+	prefix := v[:len(v)-len(rev)-len("20060102150405-")]
+	if len(rev) > 7 {
+		rev = rev[:7]
+	}
+	return prefix + rev
+}
+
+// reachable returns the set of nodes reachable from any of
+// the given roots, including the roots themselves.
+func (g *graph) reachable(roots ...string) map[string]struct{} {
+	seen := make(map[string]struct{})
+	q := list.New()
+	for _, r := range roots {
+		q.PushBack(r)
+	}
+	for q.Len() > 0 {
+		n := q.Remove(q.Front()).(string)
+		if _, ok := seen[n]; ok {
+			continue
+		}
+		seen[n] = struct{}{}
+		for to := range g.edges[n] {
+			q.PushBack(to)
+		}
+	}
+	return seen
+}
+
+// depths returns the length of the shortest path from root
+// to each node reachable from it.
+func (g *graph) depths(root string) map[string]int {
+	depths := map[string]int{root: 0}
+	q := list.New()
+	q.PushBack(root)
+	for q.Len() > 0 {
+		n := q.Remove(q.Front()).(string)
+		for to := range g.edges[n] {
+			if _, ok := depths[to]; !ok {
+				depths[to] = depths[n] + 1
+				q.PushBack(to)
+			}
+		}
+	}
+	return depths
+}
+
+// seedColors holds the stroke colors used to
+// distinguish the seeds given to -reachable-from.
+var seedColors = []string{"#e6194b", "#3cb44b", "#4363d8", "#f58231", "#911eb4", "#42d4f4"}
+
+// pruneSubtree removes the given module from g along with all the
+// modules that are reachable from the main module only through it.
+func (g *graph) pruneSubtree(module string) {
+	before := g.reachable(g.mainMod)
+	keep := difference(g.nodes, map[string]struct{}{module: {}})
+	g.keepNodes(keep)
+	after := g.reachable(g.mainMod)
+	g.keepNodes(difference(g.nodes, difference(before, after)))
+}
+
+// keepNodes removes all nodes that are not in keep,
+// along with any edges to or from them.
+func (g *graph) keepNodes(keep map[string]struct{}) {
+	for n := range g.nodes {
+		if _, ok := keep[n]; !ok {
+			delete(g.nodes, n)
+			delete(g.edges, n)
+		}
+	}
+	for _, tos := range g.edges {
+		for to := range tos {
+			if _, ok := keep[to]; !ok {
+				delete(tos, to)
+			}
+		}
+	}
+}
+
+// collapseLeaves removes every leaf node (one with no outgoing edges)
+// that has exactly one predecessor and for which choose returns true,
+// annotating each such predecessor with the number of leaves hidden below it.
+// The main module is never removed.
+func (g *graph) collapseLeaves(what string, choose func(name string) bool) {
+	preds := make(map[string][]string)
+	for from, tos := range g.edges {
+		for to := range tos {
+			preds[to] = append(preds[to], from)
+		}
+	}
+	// Decide what to hide before removing anything so that
+	// the result doesn't depend on map iteration order.
+	var leaves []string
+	for n := range g.nodes {
+		if n != g.mainMod && len(g.edges[n]) == 0 && len(preds[n]) == 1 && choose(n) {
+			leaves = append(leaves, n)
+		}
+	}
+	hidden := make(map[string]int)
+	for _, n := range leaves {
+		parent := preds[n][0]
+		delete(g.edges[parent], n)
+		delete(g.nodes, n)
+		hidden[parent]++
+	}
+	for parent, n := range hidden {
+		g.notes[parent] = append(g.notes[parent], fmt.Sprintf("(+%d %s)", n, what))
+	}
+}
+
+// testDepsNode is the name of the node that stands
+// for all the test-only modules with -collapse-test-only.
+const testDepsNode = "«test deps»"
+
+// collapseTestOnly replaces all the test-only modules in g with a
+// single test-only testDepsNode, with an edge to it from each other
+// module that has an edge to any of them. Its tooltip lists the
+// modules that it replaces.
+func (g *graph) collapseTestOnly() {
+	collapsed := intersection(g.testOnly, g.nodes)
+	delete(collapsed, g.mainMod)
+	if len(collapsed) == 0 {
+		return
+	}
+	for from, tos := range g.edges {
+		if _, ok := collapsed[from]; ok {
+			continue
+		}
+		for to := range tos {
+			if _, ok := collapsed[to]; ok {
+				delete(tos, to)
+				tos[testDepsNode] = struct{}{}
+			}
+		}
+	}
+	keep := difference(g.nodes, collapsed)
+	keep[testDepsNode] = struct{}{}
+	g.nodes[testDepsNode] = struct{}{}
+	g.keepNodes(keep)
+	g.testOnly[testDepsNode] = struct{}{}
+	g.addTooltip(testDepsNode, strings.Join(sortedKeys(collapsed), ", "))
+}
+
+// mermaidEscaper replaces the characters that cannot appear literally
+// inside a quoted mermaid label with mermaid's entity codes.
+var mermaidEscaper = strings.NewReplacer(
+	`"`, "#quot;",
+	"#", "#35;",
+	"<", "#lt;",
+	">", "#gt;",
+	"\n", " ",
+	"\r", " ",
+)
+
+// mermaidQuote returns s as a quoted mermaid label.
+// Go's %q quoting is not suitable because mermaid
+// does not understand backslash escapes.
+func mermaidQuote(s string) string {
+	return `"` + mermaidEscaper.Replace(s) + `"`
+}
+
+func intersection(a, b map[string]struct{}) map[string]struct{} {
+	res := make(map[string]struct{})
+	for k := range a {
+		if _, ok := b[k]; ok {
+			res[k] = struct{}{}
+		}
+	}
+	return res
 }
 
 func difference(a, b map[string]struct{}) map[string]struct{} {
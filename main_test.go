@@ -0,0 +1,1434 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/module"
+	modzip "golang.org/x/mod/zip"
+	"golang.org/x/tools/go/packages"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+// TestMain runs the command itself when the test binary is re-executed
+// by runMain, so that tests can check the behavior of main, including
+// its exit status, without building a separate binary.
+func TestMain(m *testing.M) {
+	if os.Getenv("TEST_GOTESTDEPS_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fixture returns the absolute path of the main module of the given
+// fixture in testdata. The fx fixture holds example.com/main, which
+// depends on example.com/a and example.com/b and, from its tests, on
+// example.com/t. The test-only modules are example.com/f and
+// example.com/g, needed by the tests of a, and example.com/x, needed by
+// an example in c. Every module is replaced by a sibling directory.
+func fixture(t testing.TB, name string) string {
+	dir, err := filepath.Abs(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// runResult holds the outcome of running the command.
+type runResult struct {
+	stdout, stderr string
+	failed         bool
+}
+
+// runMain runs the command in dir with the given arguments and any
+// extra environment variables, which are of the form NAME=value.
+// GOTESTDEPS_ variables inherited from the environment are removed
+// so that they cannot affect the results.
+func runMain(t *testing.T, dir string, env []string, args ...string) runResult {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(exe, args...)
+	cmd.Dir = dir
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "GOTESTDEPS_") {
+			cmd.Env = append(cmd.Env, kv)
+		}
+	}
+	cmd.Env = append(cmd.Env, "TEST_GOTESTDEPS_MAIN=1")
+	cmd.Env = append(cmd.Env, env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		t.Fatal(err)
+	}
+	return runResult{stdout.String(), stderr.String(), err != nil}
+}
+
+// mustRun is like runMain but fails the test if the command fails.
+func mustRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	r := runMain(t, dir, nil, args...)
+	if r.failed {
+		t.Fatalf("gotestdeps %s failed:\n%s", strings.Join(args, " "), r.stderr)
+	}
+	return r.stdout
+}
+
+// checkGolden compares got with the contents of the named file in
+// testdata, or rewrites the file if the -update flag is given.
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	file := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(file, []byte(got), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s; got:\n%s", file, got)
+	}
+}
+
+func TestJSONGolden(t *testing.T) {
+	checkGolden(t, "fx.json", mustRun(t, fixture(t, "fx/main"), "-format", "json"))
+}
+
+func TestMermaidClassGolden(t *testing.T) {
+	checkGolden(t, "fx.class.mmd", mustRun(t, fixture(t, "fx/main"), "-format", "mermaid-class"))
+}
+
+func TestWatchArgs(t *testing.T) {
+	for _, watch := range []string{"-watch-git", "-watch-git=1", "-watch-git=T", "--watch-git=true"} {
+		fs := flag.NewFlagSet("gotestdeps", flag.ContinueOnError)
+		fs.Bool("watch-git", false, "")
+		fs.String("o", "", "")
+		var layers layerRules
+		fs.Var(&layers, "layer", "")
+		if err := fs.Parse([]string{watch, "-o", "out.mmd", "-layer", "a=top", "-layer=b=bottom"}); err != nil {
+			t.Fatal(err)
+		}
+		got := strings.Join(watchArgs(fs), " ")
+		want := "-layer=a=top -layer=b=bottom -o=out.mmd -watch-git=false"
+		if got != want {
+			t.Errorf("with %s, got args %q, want %q", watch, got, want)
+		}
+	}
+	env := watchEnv([]string{"HOME=/home/x", "GOTESTDEPS_WATCH_GIT=1", "GOTESTDEPS_FORMAT=tree"})
+	if got, want := strings.Join(env, " "), "HOME=/home/x GOTESTDEPS_FORMAT=tree"; got != want {
+		t.Errorf("got environment %q, want %q", got, want)
+	}
+}
+
+func TestFlagsFromEnv(t *testing.T) {
+	env := map[string]string{
+		"GOTESTDEPS_FORMAT": "tree",
+		"GOTESTDEPS_O":      "env.out",
+		"GOTESTDEPS_LAYER":  "env=top",
+	}
+	lookupEnv := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+	fs := flag.NewFlagSet("gotestdeps", flag.ContinueOnError)
+	format := fs.String("format", "mermaid", "")
+	out := fs.String("o", "", "")
+	var layers layerRules
+	fs.Var(&layers, "layer", "")
+	if err := fs.Parse([]string{"-o", "cmd.out", "-layer", "cmd=bottom"}); err != nil {
+		t.Fatal(err)
+	}
+	setFlagsFromEnv(fs, lookupEnv)
+	if *format != "tree" {
+		t.Errorf("-format is %q, want the environment's value tree", *format)
+	}
+	if *out != "cmd.out" {
+		t.Errorf("-o is %q, want the command line's value cmd.out", *out)
+	}
+	if got := layers.String(); got != "cmd=bottom" {
+		t.Errorf("-layer is %q, want only the command line's value cmd=bottom", got)
+	}
+
+	dir := fixture(t, "fx/main")
+	r := runMain(t, dir, []string{"GOTESTDEPS_FORMAT=porcelain"})
+	if !strings.HasPrefix(r.stdout, "# porcelain 1\n") {
+		t.Errorf("with GOTESTDEPS_FORMAT=porcelain, got output:\n%s", r.stdout)
+	}
+	r = runMain(t, dir, []string{"GOTESTDEPS_FORMAT=porcelain"}, "-format", "canonical")
+	if !strings.HasPrefix(r.stdout, "# canonical 1\n") {
+		t.Errorf("with GOTESTDEPS_FORMAT=porcelain and -format canonical, got output:\n%s", r.stdout)
+	}
+}
+
+// proxyModule is a module version served by the proxy made by
+// writeProxy. Its files other than go.mod are given by files.
+type proxyModule struct {
+	path, version, goMod string
+	files                map[string]string
+}
+
+// writeProxy writes a module proxy for mods into a temporary directory
+// and returns the environment needed to use it, together with a
+// private module cache, without contacting any checksum database.
+func writeProxy(t *testing.T, mods ...proxyModule) []string {
+	t.Helper()
+	root := t.TempDir()
+	proxy := filepath.Join(root, "proxy")
+	versions := make(map[string][]string)
+	for _, m := range mods {
+		src := filepath.Join(root, "src", m.path+"@"+m.version)
+		files := map[string]string{"go.mod": m.goMod}
+		for name, data := range m.files {
+			files[name] = data
+		}
+		for name, data := range files {
+			writeFile(t, filepath.Join(src, name), data)
+		}
+		vdir := filepath.Join(proxy, m.path, "@v")
+		writeFile(t, filepath.Join(vdir, m.version+".mod"), m.goMod)
+		writeFile(t, filepath.Join(vdir, m.version+".info"), `{"Version":"`+m.version+`","Time":"2024-01-01T00:00:00Z"}`)
+		z, err := os.Create(filepath.Join(vdir, m.version+".zip"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := modzip.CreateFromDir(z, module.Version{Path: m.path, Version: m.version}, src); err != nil {
+			t.Fatal(err)
+		}
+		if err := z.Close(); err != nil {
+			t.Fatal(err)
+		}
+		versions[m.path] = append(versions[m.path], m.version)
+	}
+	for path, vs := range versions {
+		writeFile(t, filepath.Join(proxy, path, "@v", "list"), strings.Join(vs, "\n")+"\n")
+	}
+	return []string{
+		"GOPROXY=file://" + filepath.ToSlash(proxy),
+		"GOMODCACHE=" + filepath.Join(root, "modcache"),
+		"GOFLAGS=-mod=mod -modcacherw",
+		"GOSUMDB=off",
+		"GONOSUMDB=",
+		"GOPRIVATE=",
+		"GOTOOLCHAIN=local",
+		"GOWORK=off",
+	}
+}
+
+// writeFile writes data to file, creating its directory if needed.
+func writeFile(t *testing.T, file, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(file), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(data), 0o666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRetractions(t *testing.T) {
+	env := writeProxy(t,
+		proxyModule{
+			path:    "example.com/r",
+			version: "v1.0.0",
+			goMod:   "module example.com/r\n",
+			files:   map[string]string{"r.go": "package r\n"},
+		},
+		proxyModule{
+			path:    "example.com/r",
+			version: "v1.1.0",
+			goMod:   "module example.com/r\n\nretract v1.0.0 // it is broken\n",
+			files:   map[string]string{"r.go": "package r\n"},
+		},
+	)
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "go.mod"), "module example.com/main\n\ngo 1.21\n\nrequire example.com/r v1.0.0\n")
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nimport _ \"example.com/r\"\n\nfunc main() {}\n")
+
+	// The go.mod of v1.0.0 itself retracts nothing, so the retraction
+	// can only have come from the latest version.
+	r := runMain(t, dir, env, "-retractions", "-format", "mermaid")
+	if r.failed {
+		t.Fatalf("gotestdeps -retractions failed:\n%s", r.stderr)
+	}
+	if !strings.Contains(r.stdout, "it is broken") {
+		t.Errorf("output does not mention the retraction of example.com/r v1.0.0:\n%s", r.stdout)
+	}
+	r = runMain(t, dir, env, "-format", "mermaid")
+	if r.failed {
+		t.Fatalf("gotestdeps failed:\n%s", r.stderr)
+	}
+	if strings.Contains(r.stdout, "it is broken") {
+		t.Errorf("output mentions a retraction without -retractions:\n%s", r.stdout)
+	}
+}
+
+// copyDir copies the tree of files at src to dst.
+func copyDir(t *testing.T, dst, src string) {
+	t.Helper()
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		writeFile(t, filepath.Join(dst, rel), string(data))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCRLFWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   string
+	}{
+		{"none", []string{"abc"}, "abc"},
+		{"newlines", []string{"a\nb\n"}, "a\r\nb\r\n"},
+		{"already crlf", []string{"a\r\nb\n"}, "a\r\nb\r\n"},
+		{"blank lines", []string{"\n\n"}, "\r\n\r\n"},
+		{"lone cr", []string{"a\rb\n"}, "a\rb\r\n"},
+		{"crlf split across writes", []string{"a\r", "\nb"}, "a\r\nb"},
+		{"newline after earlier write", []string{"a", "\n"}, "a\r\n"},
+		{"cr then text", []string{"\r", "x\n"}, "\rx\r\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := &crlfWriter{w: &buf}
+			for _, s := range test.writes {
+				n, err := w.Write([]byte(s))
+				if err != nil || n != len(s) {
+					t.Fatalf("Write(%q) = %d, %v", s, n, err)
+				}
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestClipboardWithOutput(t *testing.T) {
+	dir := t.TempDir()
+	r := runMain(t, fixture(t, "fx/main"), nil, "-clipboard", "-o", filepath.Join(dir, "out.mmd"))
+	if !r.failed || !strings.Contains(r.stderr, "-clipboard cannot be used with -o") {
+		t.Errorf("-clipboard with -o did not fail as expected; stderr:\n%s", r.stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out.mmd")); err == nil {
+		t.Errorf("-clipboard with -o wrote the output file")
+	}
+}
+
+// serialBuildEdges is the single-threaded way that buildEdges used
+// to work, kept to check the result of the workers against.
+func serialBuildEdges(pkgs []*packages.Package) (map[string]map[string]struct{}, map[string]struct{}, map[edge]int) {
+	edges := make(map[string]map[string]struct{})
+	nodes := make(map[string]struct{})
+	counts := make(map[edge]int)
+	seenImports := make(map[edge]bool)
+	traverse(pkgs, func(p *packages.Package) {
+		from := nodeOf(p)
+		if from == "" {
+			return
+		}
+		nodes[from] = struct{}{}
+		for _, imp := range p.Imports {
+			to := nodeOf(imp)
+			if to == "" || to == from {
+				continue
+			}
+			if edges[from] == nil {
+				edges[from] = make(map[string]struct{})
+			}
+			edges[from][to] = struct{}{}
+			nodes[to] = struct{}{}
+			if pair := (edge{p.PkgPath, imp.PkgPath}); !seenImports[pair] {
+				seenImports[pair] = true
+				counts[edge{from, to}]++
+			}
+		}
+	})
+	return edges, nodes, counts
+}
+
+// syntheticPackages returns a pseudo-random set of n packages spread
+// over modules of ten packages each, in which every package imports
+// a few packages that come after it, including a test variant of
+// every tenth package.
+func syntheticPackages(n int) []*packages.Package {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	pkgs := make([]*packages.Package, n)
+	for i := range pkgs {
+		mod := &packages.Module{Path: fmt.Sprintf("example.com/m%d", i/10), Version: "v1.0.0"}
+		pkgs[i] = &packages.Package{
+			ID:      fmt.Sprint(i),
+			PkgPath: fmt.Sprintf("%s/p%d", mod.Path, i),
+			Module:  mod,
+			Imports: make(map[string]*packages.Package),
+		}
+	}
+	for i := len(pkgs) - 1; i >= 0; i-- {
+		for j := 0; j < 4 && i+1 < len(pkgs); j++ {
+			imp := pkgs[i+1+rnd.IntN(min(len(pkgs)-i-1, 50))]
+			pkgs[i].Imports[imp.PkgPath] = imp
+		}
+		if i%10 == 0 {
+			test := *pkgs[i]
+			test.ID += " [test]"
+			test.Imports = maps.Clone(pkgs[i].Imports)
+			pkgs = append(pkgs, &test)
+		}
+	}
+	return pkgs
+}
+
+func TestBuildEdges(t *testing.T) {
+	pkgs := syntheticPackages(2000)
+	edges, nodes, counts := buildEdges(pkgs)
+	wantEdges, wantNodes, wantCounts := serialBuildEdges(pkgs)
+	if !reflect.DeepEqual(edges, wantEdges) {
+		t.Errorf("edges differ from those built serially")
+	}
+	if !reflect.DeepEqual(nodes, wantNodes) {
+		t.Errorf("nodes differ from those found serially")
+	}
+	if !reflect.DeepEqual(counts, wantCounts) {
+		t.Errorf("counts differ from those made serially")
+	}
+}
+
+func BenchmarkBuildEdges(b *testing.B) {
+	pkgs := syntheticPackages(20000)
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			serialBuildEdges(pkgs)
+		}
+	})
+	b.Run("workers", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			buildEdges(pkgs)
+		}
+	})
+}
+
+// mermaidNodes returns the paths of the nodes declared
+// in the given mermaid output, in order.
+func mermaidNodes(out string) []string {
+	var nodes []string
+	for _, line := range strings.Split(out, "\n") {
+		if _, label, ok := strings.Cut(line, `["`); ok {
+			nodes = append(nodes, strings.TrimSuffix(label, `"]`))
+		}
+	}
+	return nodes
+}
+
+func TestTestFirst(t *testing.T) {
+	got := mermaidNodes(mustRun(t, fixture(t, "fx/main"), "-test-first"))
+	want := []string{
+		"example.com/f", "example.com/g", "example.com/x",
+		"example.com/a", "example.com/b", "example.com/c", "example.com/d",
+		"example.com/e", "example.com/main", "example.com/t",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got nodes in order %q, want %q", got, want)
+	}
+}
+
+// porcelainLines returns the node and edge lines of
+// the given porcelain output.
+func porcelainLines(out string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+func TestCollapseModule(t *testing.T) {
+	root := t.TempDir()
+	copyDir(t, root, fixture(t, "fx"))
+	writeFile(t, filepath.Join(root, "main", "sub", "extra.go"), "package sub\n\nimport _ \"example.com/b/extra\"\n")
+	dir := filepath.Join(root, "main")
+	out := mustRun(t, dir, "-granularity", "package", "-format", "porcelain", "-collapse-module", "example.com/b")
+	got := strings.Join(porcelainLines(out), "\n")
+	for _, want := range []string{
+		"E\texample.com/main/sub\texample.com/b",
+		// The test of example.com/b/extra is now part of example.com/b.
+		"E\texample.com/b\texample.com/h",
+		"N\texample.com/h\ttestOnlyDep\tv1.0.0",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "example.com/b/extra") {
+		t.Errorf("example.com/b/extra is not collapsed into example.com/b:\n%s", got)
+	}
+	out = mustRun(t, dir, "-granularity", "package", "-format", "porcelain")
+	if !strings.Contains(out, "E\texample.com/main/sub\texample.com/b/extra\n") {
+		t.Errorf("without -collapse-module, example.com/b/extra is not a node of its own:\n%s", out)
+	}
+	r := runMain(t, dir, nil, "-collapse-module", "example.com/b")
+	if !r.failed || !strings.Contains(r.stderr, "-collapse-module requires -granularity package") {
+		t.Errorf("-collapse-module without -granularity package did not fail as expected:\n%s", r.stderr)
+	}
+}
+
+func TestRankByDepth(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	checkGolden(t, "fx-rank-by-depth.mmd", mustRun(t, dir, "-rank-by-depth"))
+	r := runMain(t, dir, nil, "-rank-by-depth", "-group-test-only")
+	if !r.failed || !strings.Contains(r.stderr, "-rank-by-depth cannot be used with -group-by or -group-test-only") {
+		t.Errorf("-rank-by-depth with -group-test-only did not fail as expected:\n%s", r.stderr)
+	}
+}
+
+func TestOnlyTestEdges(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	got := porcelainLines(mustRun(t, dir, "-only-test-edges", "-format", "porcelain"))
+	var edges []string
+	for _, line := range got {
+		if strings.HasPrefix(line, "E\t") {
+			edges = append(edges, line)
+		}
+	}
+	want := []string{
+		"E\texample.com/a\texample.com/f",
+		"E\texample.com/c\texample.com/x",
+		"E\texample.com/f\texample.com/g",
+		"E\texample.com/main\texample.com/t",
+	}
+	if !reflect.DeepEqual(edges, want) {
+		t.Errorf("got edges %q, want %q", edges, want)
+	}
+	if out := strings.Join(got, "\n"); strings.Contains(out, "example.com/d") || strings.Contains(out, "example.com/e") {
+		t.Errorf("modules reached only by production edges are present:\n%s", out)
+	}
+	r := runMain(t, dir, nil, "-only-test-edges", "-prod-only")
+	if !r.failed {
+		t.Errorf("-only-test-edges with -prod-only did not fail")
+	}
+}
+
+// setFlag sets the named flag for the duration of the test.
+func setFlag(t *testing.T, name, value string) {
+	f := flag.Lookup(name)
+	old := f.Value.String()
+	if err := f.Value.Set(value); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		f.Value.Set(old)
+	})
+}
+
+func TestMergeBidirectional(t *testing.T) {
+	g := edgeGraph(
+		[2]string{"example.com/main", "example.com/a"},
+		[2]string{"example.com/a", "example.com/b"},
+		[2]string{"example.com/b", "example.com/a"},
+	)
+	g.mainMod = "example.com/main"
+	g.prodEdges = g.edges
+	g.edgeStyles = map[edge]string{{"example.com/b", "example.com/a"}: "stroke:red"}
+	var buf bytes.Buffer
+	writeDot(&buf, g)
+	if got := strings.Count(buf.String(), " --> "); got != 3 {
+		t.Errorf("without -merge-bidirectional, got %d links, want 3:\n%s", got, buf.String())
+	}
+
+	setFlag(t, "merge-bidirectional", "true")
+	buf.Reset()
+	writeDot(&buf, g)
+	out := buf.String()
+	for _, want := range []string{
+		"N0 <--> N1\n",
+		"N2 --> N0\n",
+		// The style of either direction applies to the merged link.
+		"linkStyle 0 stroke:red;\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+	if got := strings.Count(out, "-->"); got != 2 {
+		t.Errorf("got %d links, want 2:\n%s", got, out)
+	}
+}
+
+func TestHideMainPackages(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	out := mustRun(t, dir, "-granularity", "package", "-format", "porcelain", "-hide-main-packages")
+	if strings.Contains(out, "example.com/main/sub") {
+		t.Errorf("example.com/main/sub is not hidden:\n%s", out)
+	}
+	for _, want := range []string{
+		"N\texample.com/main\tmainModule\t\n",
+		"E\texample.com/main\texample.com/b\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+	r := runMain(t, dir, nil, "-hide-main-packages")
+	if !r.failed || !strings.Contains(r.stderr, "-hide-main-packages requires -granularity package") {
+		t.Errorf("-hide-main-packages without -granularity package did not fail as expected:\n%s", r.stderr)
+	}
+}
+
+func TestTrimPrefix(t *testing.T) {
+	g := edgeGraph([2]string{"example.com/main", "other.org/x"})
+	setFlag(t, "trim-prefix", "example.com/")
+	for _, test := range []struct {
+		name string
+		want string
+	}{
+		{"example.com/main", "main"},
+		{"other.org/x", "other.org/x"},
+		// A label is never trimmed away entirely.
+		{"example.com/", "example.com/"},
+	} {
+		if got := g.label(test.name); got != test.want {
+			t.Errorf("label(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+	got := mermaidNodes(mustRun(t, fixture(t, "fx/main"), "-trim-prefix", "example.com/"))
+	want := []string{"a", "b", "c", "d", "e", "f", "g", "main", "t", "x"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got node labels %q, want %q", got, want)
+	}
+}
+
+func TestSideBySide(t *testing.T) {
+	checkGolden(t, "fx-side-by-side.md", mustRun(t, fixture(t, "fx/main"), "-side-by-side"))
+}
+
+func TestPruneByIndegree(t *testing.T) {
+	r := runMain(t, fixture(t, "fx/main"), nil, "-prune-by-indegree", "2")
+	if r.failed {
+		t.Fatalf("-prune-by-indegree failed:\n%s", r.stderr)
+	}
+	// Only example.com/c has two incoming edges, from example.com/a
+	// and example.com/b; the modules below it are left in place.
+	want := []string{
+		"example.com/a", "example.com/b", "example.com/d", "example.com/e",
+		"example.com/f", "example.com/g", "example.com/main", "example.com/t", "example.com/x",
+	}
+	if got := mermaidNodes(r.stdout); !reflect.DeepEqual(got, want) {
+		t.Errorf("got nodes %q, want %q", got, want)
+	}
+	if !strings.Contains(r.stdout, "%% hidden ubiquitous deps: example.com/c\n") {
+		t.Errorf("output does not list the hidden module:\n%s", r.stdout)
+	}
+	if !strings.Contains(r.stderr, "hidden ubiquitous modules: example.com/c\n") {
+		t.Errorf("hidden module is not logged:\n%s", r.stderr)
+	}
+}
+
+func TestShapes(t *testing.T) {
+	out := mustRun(t, fixture(t, "fx/main"), "-shapes")
+	for _, want := range []string{
+		`N0["example.com/a"]`,
+		`N5{{"example.com/f"}}`,
+		`N7(["example.com/main"])`,
+		`N9{{"example.com/x"}}`,
+	} {
+		if !strings.Contains(out, "    "+want+"\n") {
+			t.Errorf("output does not contain %s:\n%s", want, out)
+		}
+	}
+}
+
+func TestPackage(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	got := porcelainLines(mustRun(t, dir, "-package", "example.com/main", "-format", "porcelain"))
+	var nodes []string
+	for _, line := range got {
+		if strings.HasPrefix(line, "N\t") {
+			nodes = append(nodes, line)
+		}
+	}
+	// Only the tests of example.com/main itself count, so example.com/t
+	// is test-only and the modules used by other tests are absent.
+	want := []string{
+		"N\texample.com/a\tregularDep\tv1.0.0",
+		"N\texample.com/b\tregularDep\tv1.0.0",
+		"N\texample.com/c\tregularDep\tv1.0.0",
+		"N\texample.com/d\tregularDep\tv1.0.0",
+		"N\texample.com/e\ttestOnlyDep\tv1.0.0",
+		"N\texample.com/main\tmainModule\t",
+		"N\texample.com/t\ttestOnlyDep\tv1.0.0",
+	}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("got nodes %q, want %q", nodes, want)
+	}
+	r := runMain(t, dir, nil, "-package", "./...")
+	if !r.failed || !strings.Contains(r.stderr, `-package requires a single import path, not the pattern "./..."`) {
+		t.Errorf("-package with a pattern did not fail as expected:\n%s", r.stderr)
+	}
+}
+
+func TestNoMainModule(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "x.go"), "package main\n\nfunc main() {}\n")
+	env := []string{"GOFLAGS=", "GOWORK=off"}
+	r := runMain(t, dir, env)
+	if r.failed || !strings.Contains(r.stderr, "warning: no main module found; run inside a module\n") {
+		t.Errorf("outside a module, got failed=%v and log:\n%s", r.failed, r.stderr)
+	}
+	r = runMain(t, dir, env, "-strict")
+	if !r.failed || !strings.Contains(r.stderr, "no main module found; run inside a module\n") || strings.Contains(r.stderr, "warning:") {
+		t.Errorf("outside a module with -strict, got failed=%v and log:\n%s", r.failed, r.stderr)
+	}
+}
+
+func TestAnnotateReplaceLocal(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	cache := t.TempDir()
+	// The second run takes the graph from the cache,
+	// which must keep the replacements too.
+	for range 2 {
+		r := runMain(t, dir, nil, "-annotate-replace-local", "-cache", cache)
+		if r.failed {
+			t.Fatalf("-annotate-replace-local failed:\n%s", r.stderr)
+		}
+		for _, want := range []string{
+			"local replacement: example.com/a => ../a\n",
+			"local replacement: example.com/x => ../x\n",
+		} {
+			if !strings.Contains(r.stderr, want) {
+				t.Errorf("log does not contain %q:\n%s", want, r.stderr)
+			}
+		}
+		if strings.Contains(r.stderr, "local replacement: example.com/main") {
+			t.Errorf("the main module is listed as a local replacement:\n%s", r.stderr)
+		}
+		if !strings.Contains(r.stdout, "class N0,N1,N2,N3,N4,N5,N6,N8,N9 localReplaceDep;\n") {
+			t.Errorf("local replacements are not highlighted:\n%s", r.stdout)
+		}
+	}
+	r := runMain(t, dir, nil, "-annotate-replace-local", "-strict")
+	if !r.failed || !strings.Contains(r.stderr, "found 9 modules replaced by a local directory") {
+		t.Errorf("-annotate-replace-local with -strict did not fail as expected:\n%s", r.stderr)
+	}
+}
+
+func TestOnlyNewInTests(t *testing.T) {
+	root := t.TempDir()
+	copyDir(t, root, fixture(t, "fx"))
+	writeFile(t, filepath.Join(root, "b", "btest", "btest.go"), "package btest\n\nfunc Helper() {}\n")
+	// Packages imported by the tests of the main module are part of
+	// "all", so the helper must be used by the tests of a dependency.
+	writeFile(t, filepath.Join(root, "a", "btest_test.go"), "package a\n\nimport _ \"example.com/b/btest\"\n")
+	dir := filepath.Join(root, "main")
+	out := mustRun(t, dir, "-granularity", "package", "-only-new-in-tests")
+	nodes := mermaidNodes(out)
+	var ids []string
+	// The helper package is new in the tests although its module is not.
+	for _, n := range []string{"example.com/b/btest", "example.com/f", "example.com/g", "example.com/x"} {
+		ids = append(ids, fmt.Sprint("N", slices.Index(nodes, n)))
+	}
+	if want := "class " + strings.Join(ids, ",") + " testOnlyPackage;\n"; !strings.Contains(out, want) {
+		t.Errorf("output does not contain %q:\n%s", want, out)
+	}
+	r := runMain(t, dir, nil, "-only-new-in-tests")
+	if !r.failed || !strings.Contains(r.stderr, "-only-new-in-tests requires -granularity package") {
+		t.Errorf("-only-new-in-tests without -granularity package did not fail as expected:\n%s", r.stderr)
+	}
+}
+
+func TestShowRequireComments(t *testing.T) {
+	root := t.TempDir()
+	copyDir(t, root, fixture(t, "fx"))
+	goMod := filepath.Join(root, "main", "go.mod")
+	data, err := os.ReadFile(goMod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := strings.Replace(string(data), "example.com/a v1.0.0\n", "example.com/a v1.0.0 // used for parsing\n", 1)
+	s = strings.Replace(s, "example.com/c v1.0.0 // indirect\n", "example.com/c v1.0.0 // indirect; not shown\n", 1)
+	writeFile(t, goMod, s)
+	out := mustRun(t, filepath.Join(root, "main"), "-show-require-comments")
+	if !strings.Contains(out, `click N0 href "https://pkg.go.dev/example.com/a" "used for parsing"`+"\n") {
+		t.Errorf("output does not show the comment on example.com/a:\n%s", out)
+	}
+	if strings.Contains(out, "not shown") {
+		t.Errorf("output shows the comment on an indirect requirement:\n%s", out)
+	}
+}
+
+func TestCollapseTestOnly(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	got := porcelainLines(mustRun(t, dir, "-collapse-test-only", "-format", "porcelain"))
+	want := []string{
+		"N\texample.com/a\tregularDep\tv1.0.0",
+		"N\texample.com/b\tregularDep\tv1.0.0",
+		"N\texample.com/c\tregularDep\tv1.0.0",
+		"N\texample.com/d\tregularDep\tv1.0.0",
+		"N\texample.com/e\tregularDep\tv1.0.0",
+		"N\texample.com/main\tmainModule\t",
+		"N\texample.com/t\tregularDep\tv1.0.0",
+		"N\t" + testDepsNode + "\ttestOnlyDep\t",
+		"E\texample.com/a\texample.com/c",
+		"E\texample.com/a\t" + testDepsNode,
+		"E\texample.com/b\texample.com/c",
+		"E\texample.com/b\texample.com/d",
+		"E\texample.com/c\t" + testDepsNode,
+		"E\texample.com/main\texample.com/a",
+		"E\texample.com/main\texample.com/b",
+		"E\texample.com/main\texample.com/t",
+		"E\texample.com/t\texample.com/e",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	out := mustRun(t, dir, "-collapse-test-only")
+	if !strings.Contains(out, `"example.com/f, example.com/g, example.com/x"`+"\n") {
+		t.Errorf("the tooltip does not list the collapsed modules:\n%s", out)
+	}
+}
+
+func TestNoExternalDependencies(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "go.mod"), "module example.com/lone\n\ngo 1.22\n")
+	writeFile(t, filepath.Join(dir, "lone.go"), "package lone\n\nimport \"fmt\"\n\nfunc F() { fmt.Println() }\n")
+	r := runMain(t, dir, nil)
+	if r.failed || !strings.Contains(r.stderr, "note: example.com/lone has no external module dependencies\n") {
+		t.Errorf("got failed=%v and log:\n%s", r.failed, r.stderr)
+	}
+	if !strings.Contains(r.stdout, "    %% no external module dependencies\n") {
+		t.Errorf("output has no comment saying there are no dependencies:\n%s", r.stdout)
+	}
+	if r := runMain(t, fixture(t, "fx/main"), nil); strings.Contains(r.stderr+r.stdout, "no external module dependencies") {
+		t.Errorf("a module with dependencies is said to have none:\n%s%s", r.stderr, r.stdout)
+	}
+}
+
+func TestIncludeStdlib(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	out := mustRun(t, dir, "-granularity", "package", "-format", "porcelain")
+	if strings.Contains(out, "\ttesting\n") {
+		t.Errorf("standard library packages are shown without -include-stdlib:\n%s", out)
+	}
+	out = mustRun(t, dir, "-granularity", "package", "-format", "porcelain", "-include-stdlib")
+	for _, want := range []string{"E\texample.com/main\ttesting\n", "E\ttesting\tflag\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("with -include-stdlib, output does not contain %q", want)
+		}
+	}
+	out = mustRun(t, dir, "-granularity", "package", "-format", "porcelain", "-include-stdlib", "-collapse-std")
+	for _, want := range []string{"N\tstd\tregularDep\t\n", "E\texample.com/main\tstd\n", "E\texample.com/a\tstd\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("with -collapse-std, output does not contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "testing") {
+		t.Errorf("with -collapse-std, standard library packages are shown:\n%s", out)
+	}
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-include-stdlib"}, "-include-stdlib requires -granularity package"},
+		{[]string{"-granularity", "package", "-collapse-std"}, "-collapse-std requires -include-stdlib"},
+		{[]string{"-granularity", "file"}, `invalid -granularity value "file" (want module or package)`},
+	} {
+		r := runMain(t, dir, nil, test.args...)
+		if !r.failed || !strings.Contains(r.stderr, test.want) {
+			t.Errorf("%q did not fail with %q:\n%s", test.args, test.want, r.stderr)
+		}
+	}
+}
+
+func TestEdgeStyle(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	out := mustRun(t, dir, "-edge-style", "prod=thick,test=dotted")
+	for _, want := range []string{
+		"N7 ==> N0\n",
+		// The edges found only when tests are loaded.
+		"N0 -.-> N5\n",
+		"N5 -.-> N6\n",
+		"N7 -.-> N8\n",
+	} {
+		if !strings.Contains(out, "    "+want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+	if out := mustRun(t, dir, "-edge-style", "dotted"); strings.Contains(out, "-->") {
+		t.Errorf("with -edge-style dotted, some edges are solid:\n%s", out)
+	}
+	for _, bad := range []string{"bogus", "prod=wavy", "other=solid"} {
+		r := runMain(t, dir, nil, "-edge-style", bad)
+		if !r.failed || !strings.Contains(r.stderr, fmt.Sprintf("invalid -edge-style value %q", bad)) {
+			t.Errorf("-edge-style %s did not fail as expected:\n%s", bad, r.stderr)
+		}
+	}
+}
+
+func TestThemeFile(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	theme := filepath.Join(t.TempDir(), "theme.json")
+	writeFile(t, theme, `{"primaryColor": "#ff0000", "fontSize": "18px"}`)
+	out := mustRun(t, dir, "-theme-file", theme)
+	want := "```mermaid\n" + `%%{init: {"theme":"base","themeVariables":{"fontSize":"18px","primaryColor":"#ff0000"}}}%%` + "\ngraph LR\n"
+	if !strings.HasPrefix(out, want) {
+		t.Errorf("output does not start with %q:\n%s", want, out)
+	}
+	bad := filepath.Join(t.TempDir(), "bad.json")
+	writeFile(t, bad, `["not", "an", "object"]`)
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-theme-file", bad}, "invalid theme file " + bad},
+		{[]string{"-theme-file", theme, "-format", "dot"}, "-theme-file applies only to the mermaid and html formats"},
+	} {
+		r := runMain(t, dir, nil, test.args...)
+		if !r.failed || !strings.Contains(r.stderr, test.want) {
+			t.Errorf("%q did not fail with %q:\n%s", test.args, test.want, r.stderr)
+		}
+	}
+}
+
+func TestCollapseLeaves(t *testing.T) {
+	g := edgeGraph(
+		[2]string{"main", "p"},
+		[2]string{"main", "q"},
+		[2]string{"main", "m1"},
+		[2]string{"p", "l1"},
+		[2]string{"p", "l2"},
+		[2]string{"p", "t1"},
+		// shared has two parents, so it stays.
+		[2]string{"p", "shared"},
+		[2]string{"q", "shared"},
+	)
+	g.mainMod = "main"
+	g.notes = make(map[string][]string)
+	g.testOnly = map[string]struct{}{"t1": {}}
+	isTestOnly := func(name string) bool {
+		_, ok := g.testOnly[name]
+		return ok
+	}
+	g.collapseLeaves("leaves", func(name string) bool { return !isTestOnly(name) })
+	g.collapseLeaves("test leaves", isTestOnly)
+	if got, want := sortedKeys(g.nodes), []string{"main", "p", "q", "shared"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got nodes %q, want %q", got, want)
+	}
+	wantNotes := map[string][]string{
+		"main": {"(+1 leaves)"},
+		"p":    {"(+2 leaves)", "(+1 test leaves)"},
+	}
+	if !reflect.DeepEqual(g.notes, wantNotes) {
+		t.Errorf("got notes %v, want %v", g.notes, wantNotes)
+	}
+	if got, want := sortedKeys(g.edges["p"]), []string{"shared"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got edges from p to %q, want %q", got, want)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	got := mermaidNodes(mustRun(t, fixture(t, "fx/main"), "-compact", "-prune-test-leaves"))
+	want := []string{
+		"example.com/a",
+		"example.com/b (+1 leaves)",
+		"example.com/c (+1 test leaves)",
+		"example.com/f (+1 test leaves)",
+		"example.com/main",
+		"example.com/t (+1 leaves)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got nodes %q, want %q", got, want)
+	}
+}
+
+func TestNoEdgesToMain(t *testing.T) {
+	root := t.TempDir()
+	copyDir(t, root, fixture(t, "fx"))
+	writeFile(t, filepath.Join(root, "e", "e.go"), "package e\n\nimport \"example.com/main/sub\"\n\nfunc E() { sub.S() }\n")
+	dir := filepath.Join(root, "main")
+	if out := mustRun(t, dir, "-format", "porcelain"); !strings.Contains(out, "E\texample.com/e\texample.com/main\n") {
+		t.Fatalf("example.com/e does not depend on the main module:\n%s", out)
+	}
+	out := mustRun(t, dir, "-format", "porcelain", "-no-edges-to-main")
+	if strings.Contains(out, "\texample.com/main\n") {
+		t.Errorf("edges into the main module remain:\n%s", out)
+	}
+	for _, want := range []string{"E\texample.com/main\texample.com/t\n", "E\texample.com/t\texample.com/e\n"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestCountMainTestsAsProd(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	// With the "all" pattern, which includes the main module's
+	// tests, the flag makes no difference, so use -package.
+	args := []string{"-format", "porcelain", "-package", "example.com/main"}
+	out := mustRun(t, dir, args...)
+	if !strings.Contains(out, "N\texample.com/t\ttestOnlyDep\t") {
+		t.Fatalf("example.com/t is not test-only without -count-main-tests-as-prod:\n%s", out)
+	}
+	out = mustRun(t, dir, append(args, "-count-main-tests-as-prod")...)
+	for _, want := range []string{"N\texample.com/t\tregularDep\t", "N\texample.com/e\tregularDep\t"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "testOnlyDep") {
+		t.Errorf("modules used by the main module's tests are still test-only:\n%s", out)
+	}
+}
+
+func TestModuleDirs(t *testing.T) {
+	fx := fixture(t, "fx")
+	g, _ := loadGraph(filepath.Join(fx, "main"), []string{"all"})
+	for _, m := range []string{"a", "f", "x"} {
+		// The locally replaced modules are in the
+		// directories named by the replace directives.
+		want := filepath.Join(fx, m)
+		if got := g.dirs["example.com/"+m]; got != want {
+			t.Errorf("got directory %q for example.com/%s, want %q", got, m, want)
+		}
+	}
+	// The directory is used as is to find the module's files.
+	if size := moduleSize(g.dirs["example.com/a"]); size == "(size unknown)" {
+		t.Errorf("cannot find the size of example.com/a in %s", g.dirs["example.com/a"])
+	}
+}
+
+func TestPackageCounts(t *testing.T) {
+	root := t.TempDir()
+	copyDir(t, root, fixture(t, "fx"))
+	writeFile(t, filepath.Join(root, "main", "sub", "extra.go"), "package sub\n\nimport _ \"example.com/b/extra\"\n")
+	got := mermaidNodes(mustRun(t, filepath.Join(root, "main"), "-package-counts"))
+	for _, want := range []string{
+		"example.com/a [1p]",
+		// Both example.com/b and example.com/b/extra are used.
+		"example.com/b [2p]",
+		"example.com/h [1p]",
+		"example.com/main [2p]",
+	} {
+		if !slices.Contains(got, want) {
+			t.Errorf("no node labeled %q in %q", want, got)
+		}
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	t.Setenv("GOFLAGS", "-tags=x")
+	for _, test := range []struct {
+		flags map[string]string
+		want  []string
+	}{
+		{nil, nil},
+		{map[string]string{"goos": "windows"}, []string{"GOOS=windows"}},
+		{map[string]string{"goos": "linux", "goarch": "arm64"}, []string{"GOOS=linux", "GOARCH=arm64"}},
+		{map[string]string{"offline": "true"}, []string{"GOPROXY=off", "GOFLAGS=-tags=x -mod=mod"}},
+	} {
+		t.Run(fmt.Sprint(test.flags), func(t *testing.T) {
+			for name, value := range test.flags {
+				setFlag(t, name, value)
+			}
+			cfg := newConfig("", false)
+			if len(test.want) == 0 {
+				if cfg.Env != nil {
+					t.Errorf("got environment %q, want the default", cfg.Env)
+				}
+				return
+			}
+			// The variables come after the inherited
+			// environment so that they take precedence.
+			if got := cfg.Env[len(cfg.Env)-len(test.want):]; !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got environment ending %q, want %q", got, test.want)
+			}
+			if got, want := len(cfg.Env), len(os.Environ())+len(test.want); got != want {
+				t.Errorf("got %d variables, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestMermaidQuote(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		want string
+	}{
+		{"example.com/a", `"example.com/a"`},
+		{`say "hi"`, `"say #quot;hi#quot;"`},
+		// The # of an entity code is itself escaped first.
+		{"#quot;", `"#35;quot;"`},
+		{"C#", `"C#35;"`},
+		// A semicolon ends a statement only outside quotes.
+		{"a;b", `"a;b"`},
+		{"<v2>", `"#lt;v2#gt;"`},
+		{"two\nlines\r", `"two lines "`},
+	} {
+		if got := mermaidQuote(test.s); got != test.want {
+			t.Errorf("mermaidQuote(%q) = %s, want %s", test.s, got, test.want)
+		}
+	}
+}
+
+func TestProdOnly(t *testing.T) {
+	got := porcelainLines(mustRun(t, fixture(t, "fx/main"), "-prod-only", "-format", "porcelain"))
+	// The "all" pattern includes example.com/t, so it stays,
+	// but the edge to it is made by a test and so goes.
+	want := []string{
+		"N\texample.com/a\tregularDep\tv1.0.0",
+		"N\texample.com/b\tregularDep\tv1.0.0",
+		"N\texample.com/c\tregularDep\tv1.0.0",
+		"N\texample.com/d\tregularDep\tv1.0.0",
+		"N\texample.com/e\tregularDep\tv1.0.0",
+		"N\texample.com/main\tmainModule\t",
+		"N\texample.com/t\tregularDep\tv1.0.0",
+		"E\texample.com/a\texample.com/c",
+		"E\texample.com/b\texample.com/c",
+		"E\texample.com/b\texample.com/d",
+		"E\texample.com/main\texample.com/a",
+		"E\texample.com/main\texample.com/b",
+		"E\texample.com/t\texample.com/e",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestShortVersion(t *testing.T) {
+	for _, test := range []struct {
+		v    string
+		want string
+	}{
+		{"v1.2.3", "v1.2.3"},
+		{"v1.2.3-rc.1", "v1.2.3-rc.1"},
+		{"v2.0.0+incompatible", "v2.0.0"},
+		{"", ""},
+		{"v0.0.0-20231201120000-abcdef123456", "v0.0.0-abcdef1"},
+		{"v1.2.4-0.20231201120000-abcdef123456", "v1.2.4-0.abcdef1"},
+		{"v1.2.3-pre.0.20231201120000-abcdef123456", "v1.2.3-pre.0.abcdef1"},
+		{"v4.0.0-20231201120000-abcdef123456+incompatible", "v4.0.0-abcdef1"},
+	} {
+		if got := shortVersion(test.v); got != test.want {
+			t.Errorf("shortVersion(%q) = %q, want %q", test.v, got, test.want)
+		}
+	}
+}
+
+func TestTestOnlyModeDirect(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	out := mustRun(t, dir, "-test-only-mode", "direct", "-format", "porcelain")
+	for _, want := range []string{
+		// Imported by the test files of example.com/a and example.com/c.
+		"N\texample.com/f\ttestOnlyDep\t",
+		"N\texample.com/x\ttestOnlyDep\t",
+		// Needed only by tests, but imported only by example.com/f.
+		"N\texample.com/g\tregularDep\t",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+	r := runMain(t, dir, nil, "-test-only-mode", "indirect")
+	if !r.failed || !strings.Contains(r.stderr, `invalid -test-only-mode value "indirect" (want transitive or direct)`) {
+		t.Errorf("unknown -test-only-mode did not fail as expected:\n%s", r.stderr)
+	}
+}
+
+func TestInlineStyles(t *testing.T) {
+	out := mustRun(t, fixture(t, "fx/main"), "-inline-styles")
+	checkGolden(t, "fx-inline-styles.mmd", out)
+	if strings.Contains(out, "classDef") || strings.Contains(out, "\n    class ") {
+		t.Errorf("output with -inline-styles uses classes:\n%s", out)
+	}
+	if !strings.Contains(out, "    style N7 fill:#ddffdd,stroke:#333,stroke-width:1px;\n") {
+		t.Errorf("the main module has no inline style:\n%s", out)
+	}
+}
+
+func TestTruncateMiddle(t *testing.T) {
+	for _, test := range []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"example.com/a", 13, "example.com/a"},
+		{"github.com/foo/bar/baz", 15, "github.com…/baz"},
+		{"github.com/foo/bar/baz", 16, "github.com/…/baz"},
+		// The first and last elements do not fit.
+		{"github.com/foo/bar/baz", 14, "github.com/fo…"},
+		{"abcdefgh", 5, "abcd…"},
+		{"abcdefgh", 1, "…"},
+		{"abcdefgh", 0, ""},
+		// Lengths are counted in characters, not bytes.
+		{"例え.com/ä/ö/ü", 12, "例え.com/ä/ö/ü"},
+		{"例え.com/ä/ö/ü", 10, "例え.com/…/ü"},
+		{"例え.com/ä/ö/ü", 5, "例え.c…"},
+	} {
+		got := truncateMiddle(test.s, test.n)
+		if got != test.want {
+			t.Errorf("truncateMiddle(%q, %d) = %q, want %q", test.s, test.n, got, test.want)
+		}
+		if l := len([]rune(got)); l > max(test.n, 0) {
+			t.Errorf("truncateMiddle(%q, %d) has %d characters", test.s, test.n, l)
+		}
+	}
+}
+
+func TestPruneSubtree(t *testing.T) {
+	g := edgeGraph(
+		[2]string{"main", "a"},
+		[2]string{"main", "b"},
+		[2]string{"a", "c"},
+		[2]string{"a", "d"},
+		[2]string{"c", "e"},
+		// d stays because it is also reachable through b.
+		[2]string{"b", "d"},
+		// A cycle back to a does not keep c alive.
+		[2]string{"c", "a"},
+		// A module that was never reachable is left alone.
+		[2]string{"other", "c"},
+	)
+	g.mainMod = "main"
+	g.pruneSubtree("a")
+	if got, want := sortedKeys(g.nodes), []string{"b", "d", "main", "other"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got nodes %q, want %q", got, want)
+	}
+	want := map[string]map[string]struct{}{
+		"main":  {"b": {}},
+		"b":     {"d": {}},
+		"other": {},
+	}
+	if !reflect.DeepEqual(g.edges, want) {
+		t.Errorf("got edges %v, want %v", g.edges, want)
+	}
+}
+
+func TestStripVersionSuffix(t *testing.T) {
+	setFlag(t, "strip-version-suffix", "true")
+	g := &graph{
+		notes: map[string][]string{
+			"example.com/b/v2": {"[3p]"},
+		},
+	}
+	for _, test := range []struct {
+		name string
+		want string
+	}{
+		{"example.com/a/v2", "example.com/a (v2)"},
+		// The badge comes before any other notes.
+		{"example.com/b/v2", "example.com/b (v2) [3p]"},
+		{"gopkg.in/yaml.v3", "gopkg.in/yaml (v3)"},
+		{"example.com/c", "example.com/c"},
+		// A v1 gopkg.in path still has a suffix.
+		{"gopkg.in/check.v1", "gopkg.in/check (v1)"},
+	} {
+		if got := g.label(test.name); got != test.want {
+			t.Errorf("label(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSplitByGroup(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	out := filepath.Join(t.TempDir(), "groups")
+	mustRun(t, dir, "-group-by", "org", "-split-by-group", "-o", out, "-format", "porcelain,mermaid")
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	// Every module is an organization of its own in the fixture.
+	var want []string
+	for _, m := range []string{"a", "b", "c", "d", "e", "f", "g", "main", "t", "x"} {
+		want = append(want, "example.com_"+m+".mmd", "example.com_"+m+".tsv")
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got files %q, want %q", names, want)
+	}
+	// A group's file holds the edges to and from its members
+	// along with the modules at their other ends.
+	data, err := os.ReadFile(filepath.Join(out, "example.com_a.tsv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(porcelainLines(string(data)), "\n")
+	wantLines := strings.Join([]string{
+		"N\texample.com/a\tregularDep\tv1.0.0",
+		"N\texample.com/c\tregularDep\tv1.0.0",
+		"N\texample.com/f\ttestOnlyDep\tv1.0.0",
+		"N\texample.com/main\tmainModule\t",
+		"E\texample.com/a\texample.com/c",
+		"E\texample.com/a\texample.com/f",
+		"E\texample.com/main\texample.com/a",
+	}, "\n")
+	if got != wantLines {
+		t.Errorf("got group a:\n%s\nwant:\n%s", got, wantLines)
+	}
+
+	r := runMain(t, dir, nil, "-split-by-group", "-o", out)
+	if want := "-split-by-group requires -group-by and an -o directory"; !r.failed || !strings.Contains(r.stderr, want) {
+		t.Errorf("-split-by-group without -group-by did not fail with %q:\n%s", want, r.stderr)
+	}
+}
+
+func TestBaselineEdges(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	baseline := filepath.Join(t.TempDir(), "baseline")
+	// Porcelain edge lines and go mod graph lines may be mixed.
+	writeFile(t, baseline, ""+
+		"# porcelain 1\n"+
+		"E\texample.com/main\texample.com/a\n"+
+		"example.com/main example.com/b@v1.0.0\n"+
+		"example.com/a@v1.0.0 example.com/c@v1.0.0\n"+
+		"\n")
+	out := mustRun(t, dir, "-baseline-edges", baseline)
+	// Edges are numbered in order of source and then target:
+	// only a->c (0), main->a (6) and main->b (7) are known.
+	if want := "\n    linkStyle 1,2,3,4,5,8,9 " + newEdgeStyle + ";\n"; !strings.Contains(out, want) {
+		t.Errorf("output does not contain %q:\n%s", want, out)
+	}
+	r := runMain(t, dir, nil, "-baseline-edges", filepath.Join(dir, "nonexistent"))
+	if want := "cannot read baseline edges: open "; !r.failed || !strings.Contains(r.stderr, want) {
+		t.Errorf("missing baseline did not fail with %q:\n%s", want, r.stderr)
+	}
+}
+
+func TestUsageInMain(t *testing.T) {
+	got := mermaidNodes(mustRun(t, fixture(t, "fx/main"), "-usage-in-main"))
+	// example.com/main and example.com/main/sub both import
+	// example.com/b, while example.com/t is imported only by a test.
+	want := []string{
+		"example.com/a (used by 1)",
+		"example.com/b (used by 2)",
+		"example.com/c",
+		"example.com/d",
+		"example.com/e",
+		"example.com/f",
+		"example.com/g",
+		"example.com/main",
+		"example.com/t (used by 0)",
+		"example.com/x",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got node labels %q, want %q", got, want)
+	}
+}
+
+func TestPruneUnreachable(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	// Without its test-only edge from example.com/main,
+	// nothing leads to example.com/t or to the example.com/e it imports.
+	out := strings.Join(porcelainLines(mustRun(t, dir, "-prod-only", "-format", "porcelain")), "\n")
+	if !strings.Contains(out, "N\texample.com/t\t") {
+		t.Fatalf("-prod-only alone dropped example.com/t:\n%s", out)
+	}
+	got := porcelainLines(mustRun(t, dir, "-prod-only", "-prune-unreachable", "-format", "porcelain"))
+	want := []string{
+		"N\texample.com/a\tregularDep\tv1.0.0",
+		"N\texample.com/b\tregularDep\tv1.0.0",
+		"N\texample.com/c\tregularDep\tv1.0.0",
+		"N\texample.com/d\tregularDep\tv1.0.0",
+		"N\texample.com/main\tmainModule\t",
+		"E\texample.com/a\texample.com/c",
+		"E\texample.com/b\texample.com/c",
+		"E\texample.com/b\texample.com/d",
+		"E\texample.com/main\texample.com/a",
+		"E\texample.com/main\texample.com/b",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestBroken(t *testing.T) {
+	dir := fixture(t, "fx/main3")
+	r := runMain(t, dir, nil)
+	if !r.failed || !strings.Contains(r.stderr, "aborting due to previous errors") {
+		t.Errorf("load errors without -broken did not abort:\n%s", r.stderr)
+	}
+	r = runMain(t, dir, nil, "-broken")
+	if r.failed {
+		t.Fatalf("-broken failed:\n%s", r.stderr)
+	}
+	// example.com/b loads cleanly, so only example.com/bad is broken.
+	if want := "broken: no package in example.com/bad loaded without errors\n"; !strings.Contains(r.stderr, want) || strings.Count(r.stderr, "broken: ") != 1 {
+		t.Errorf("log does not name just example.com/bad as broken:\n%s", r.stderr)
+	}
+	if got, want := mermaidNodes(r.stdout)[1], "example.com/bad"; got != want {
+		t.Fatalf("got N1 %q, want %q", got, want)
+	}
+	for _, want := range []string{
+		"    classDef brokenDep " + brokenStyle + ";\n",
+		"    class N1 brokenDep;\n",
+	} {
+		if !strings.Contains(r.stdout, want) {
+			t.Errorf("output does not contain %q:\n%s", want, r.stdout)
+		}
+	}
+}
+
+func TestPackageGranularityModuleFacts(t *testing.T) {
+	root := t.TempDir()
+	copyDir(t, root, fixture(t, "fx"))
+	gomod, err := os.ReadFile(filepath.Join(root, "b", "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(root, "b", "go.mod"), "// Deprecated: use example.com/c.\n"+string(gomod))
+	writeFile(t, filepath.Join(root, "main", "sub", "sub.go"), "package sub\n\nimport (\n\t\"example.com/b\"\n\t_ \"example.com/b/extra\"\n)\n\nfunc S() { b.B() }\n")
+	r := runMain(t, filepath.Join(root, "main"), nil, "-granularity", "package", "-deprecations", "-annotate-replace-local", "-package-counts")
+	if r.failed {
+		t.Fatalf("gotestdeps failed:\n%s", r.stderr)
+	}
+	// What is known about example.com/b applies to all its packages.
+	if want := "local replacement: example.com/b/extra => ../b\n"; !strings.Contains(r.stderr, want) {
+		t.Errorf("log does not contain %q:\n%s", want, r.stderr)
+	}
+	nodes := mermaidNodes(r.stdout)
+	if want := fmt.Sprintf("class N%d,N%d deprecatedDep;\n", slices.Index(nodes, "example.com/b [1p]"), slices.Index(nodes, "example.com/b/extra [1p]")); !strings.Contains(r.stdout, want) {
+		t.Errorf("output does not contain %q:\n%s", want, r.stdout)
+	}
+	// Each package of the main module is counted once, for its own node.
+	for _, want := range []string{"example.com/main [1p]", "example.com/main/sub [1p]"} {
+		if !slices.Contains(nodes, want) {
+			t.Errorf("no node labeled %q in %q", want, nodes)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeMake writes g as make-style dependency rules, one
+// for each module, listing the modules it depends on directly.
+func writeMake(out io.Writer, g *graph) {
+	cg := canonicalize(g)
+	edges := cg.edges
+	for _, n := range cg.nodes {
+		fmt.Fprintf(out, "%s:", makeEscaper.Replace(n.path))
+		// The edges are in the same order as the nodes they
+		// come from, so those of n are always next.
+		for ; len(edges) > 0 && edges[0].from == n.path; edges = edges[1:] {
+			fmt.Fprintf(out, " %s", makeEscaper.Replace(edges[0].to))
+		}
+		fmt.Fprintf(out, "\n")
+	}
+}
+
+// makeEscaper escapes the characters that are special
+// in the targets and prerequisites of a make rule.
+var makeEscaper = strings.NewReplacer(
+	" ", `\ `,
+	"#", `\#`,
+	":", `\:`,
+	"$", "$$",
+)
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteMake(t *testing.T) {
+	g := edgeGraph(
+		[2]string{"example.com/main", "example.com/a"},
+		[2]string{"example.com/main", "example.com/x:y"},
+		[2]string{"example.com/a", "example.com/$v#1 2"},
+	)
+	g.mainMod = "example.com/main"
+	var buf bytes.Buffer
+	writeMake(&buf, g)
+	want := "" +
+		`example.com/$$v\#1\ 2:` + "\n" +
+		`example.com/a: example.com/$$v\#1\ 2` + "\n" +
+		`example.com/main: example.com/a example.com/x\:y` + "\n" +
+		`example.com/x\:y:` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got rules:\n%s\nwant:\n%s", got, want)
+	}
+}
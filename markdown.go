@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// writeMarkdown writes a GitHub-flavored markdown summary of g: a table
+// of the test-only modules followed by the mermaid diagram, which GitHub
+// renders natively. It is intended for CI job summaries.
+func writeMarkdown(out io.Writer, g *graph) {
+	introducers := g.testIntroducers()
+	fmt.Fprintf(out, "## Test-only dependencies of %s\n\n", markdownEscape(g.mainMod))
+	if len(introducers) == 0 {
+		fmt.Fprintf(out, "There are no test-only dependencies.\n\n")
+	} else {
+		fmt.Fprintf(out, "| Module | Version | Introduced by |\n")
+		fmt.Fprintf(out, "| --- | --- | --- |\n")
+		for _, m := range sortedKeys(introducers) {
+			fmt.Fprintf(out, "| %s | %s | %s |\n",
+				markdownEscape(m),
+				markdownEscape(g.versions[m]),
+				markdownEscape(strings.Join(introducers[m], ", ")),
+			)
+		}
+		fmt.Fprintf(out, "\n")
+	}
+	writeDot(out, g)
+}
+
+// testIntroducers returns, for each test-only module in g, the sorted
+// modules that are not test-only but whose tests lead to it, directly
+// or through other test-only modules.
+func (g *graph) testIntroducers() map[string][]string {
+	preds := make(map[string][]string)
+	for from, tos := range g.edges {
+		for to := range tos {
+			preds[to] = append(preds[to], from)
+		}
+	}
+	res := make(map[string][]string)
+	for m := range g.testOnly {
+		if _, ok := g.nodes[m]; !ok || m == g.mainMod {
+			continue
+		}
+		found := make(map[string]struct{})
+		seen := map[string]bool{m: true}
+		queue := []string{m}
+		for len(queue) > 0 {
+			n := queue[0]
+			queue = queue[1:]
+			for _, p := range preds[n] {
+				if seen[p] {
+					continue
+				}
+				seen[p] = true
+				if _, ok := g.testOnly[p]; ok && p != g.mainMod {
+					queue = append(queue, p)
+				} else {
+					found[p] = struct{}{}
+				}
+			}
+		}
+		res[m] = sortedKeys(found)
+	}
+	return res
+}
+
+// markdownEscaper escapes the characters that are special
+// inside a GitHub-flavored markdown table cell.
+var markdownEscaper = strings.NewReplacer(
+	"|", `\|`,
+	"*", `\*`,
+	"_", `\_`,
+	"`", "\\`",
+	"\n", " ",
+)
+
+func markdownEscape(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// showTestContext reduces g to its test-only modules and the modules
+// whose tests introduce them, as found by testIntroducers, labeling
+// each edge by which a test-only module is first reached.
+func (g *graph) showTestContext() {
+	keep := make(map[string]struct{})
+	for m, introducers := range g.testIntroducers() {
+		keep[m] = struct{}{}
+		for _, n := range introducers {
+			keep[n] = struct{}{}
+		}
+	}
+	g.keepNodes(keep)
+	for from, tos := range g.edges {
+		if _, ok := g.testOnly[from]; ok {
+			continue
+		}
+		for to := range tos {
+			if _, ok := g.testOnly[to]; ok {
+				g.edgeLabels[edge{from, to}] = "(test)"
+			}
+		}
+	}
+}
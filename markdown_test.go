@@ -0,0 +1,7 @@
+package main
+
+import "testing"
+
+func TestTestContextGolden(t *testing.T) {
+	checkGolden(t, "fx-test-context.mmd", mustRun(t, fixture(t, "fx/main"), "-test-context"))
+}
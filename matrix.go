@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// maxMatrixNodes is the number of nodes beyond which
+// writeMatrix warns that its output is likely to be unwieldy.
+const maxMatrixNodes = 500
+
+// writeMatrix writes the adjacency matrix of g as tab-separated values.
+// It starts with a legend of comment lines mapping each index to its
+// module, followed by a header row of indices and then a row for each
+// module. The cell in row i and column j holds the number of package
+// imports from module i to module j, or 1 if that is unknown, and 0
+// if there is no edge. Rows and columns are in declaration order.
+func writeMatrix(out io.Writer, g *graph) {
+	nodes := g.nodeOrder()
+	if len(nodes) > maxMatrixNodes {
+		log.Printf("warning: writing a %d by %d adjacency matrix", len(nodes), len(nodes))
+	}
+	for i, n := range nodes {
+		fmt.Fprintf(out, "# %d\t%s\n", i, n)
+	}
+	cells := make([]string, len(nodes)+1)
+	for j := range nodes {
+		cells[j+1] = fmt.Sprint(j)
+	}
+	fmt.Fprintf(out, "%s\n", strings.Join(cells, "\t"))
+	for i, from := range nodes {
+		cells[0] = fmt.Sprint(i)
+		for j, to := range nodes {
+			cell := 0
+			if _, ok := g.edges[from][to]; ok {
+				cell = max(g.counts[edge{from, to}], 1)
+			}
+			cells[j+1] = fmt.Sprint(cell)
+		}
+		fmt.Fprintf(out, "%s\n", strings.Join(cells, "\t"))
+	}
+}
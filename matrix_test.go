@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteMatrix(t *testing.T) {
+	g := edgeGraph(
+		[2]string{"example.com/main", "example.com/a"},
+		[2]string{"example.com/main", "example.com/b"},
+		[2]string{"example.com/a", "example.com/b"},
+	)
+	g.counts = map[edge]int{{"example.com/main", "example.com/b"}: 3}
+	var buf bytes.Buffer
+	writeMatrix(&buf, g)
+	want := "" +
+		"# 0\texample.com/a\n" +
+		"# 1\texample.com/b\n" +
+		"# 2\texample.com/main\n" +
+		"\t0\t1\t2\n" +
+		"0\t0\t1\t0\n" +
+		"1\t0\t0\t0\n" +
+		"2\t1\t3\t0\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got matrix:\n%s\nwant:\n%s", got, want)
+	}
+}
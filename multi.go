@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// mainColors holds the fill colors used to distinguish
+// the main modules of a graph merged by -multi.
+var mainColors = []string{"#ddffdd", "#ffffcc", "#ccf2ff", "#ffe0f0", "#e8dcff", "#ffe4c4"}
+
+// mergeGraphs returns the union of the given graphs, each loaded from
+// a different main module, so that dependencies they share appear only
+// once. A module is test-only in the result only if it is test-only in
+// every graph that contains it. The main module of the first graph is
+// the main module of the result and each graph's main module is given
+// its own fill color.
+func mergeGraphs(gs []*graph) *graph {
+	m := &graph{
+		mainMod:    gs[0].mainMod,
+		nodes:      make(map[string]struct{}),
+		edges:      make(map[string]map[string]struct{}),
+		testOnly:   make(map[string]struct{}),
+		versions:   make(map[string]string),
+		replaces:   make(map[string]string),
+		pkgCounts:  make(map[string]int),
+		prodEdges:  make(map[string]map[string]struct{}),
+		counts:     make(map[edge]int),
+		edgeStyles: make(map[edge]string),
+		edgeLabels: make(map[edge]string),
+		notes:      make(map[string][]string),
+		deprecated: make(map[string]string),
+		retracted:  make(map[string]string),
+		mainUsage:  make(map[string]int),
+		dirs:       make(map[string]string),
+		tooltips:   make(map[string]string),
+		conflicts:  make(map[string]map[string][]string),
+
+		localReplaces: make(map[string]struct{}),
+		testOnlyPkgs:  make(map[string]struct{}),
+		broken:        make(map[string]struct{}),
+	}
+	union := func(dst, src map[string]map[string]struct{}) {
+		for from, tos := range src {
+			if dst[from] == nil {
+				dst[from] = make(map[string]struct{})
+			}
+			maps.Copy(dst[from], tos)
+		}
+	}
+	prod := make(map[string]bool)
+	for i, g := range gs {
+		for n := range g.nodes {
+			m.nodes[n] = struct{}{}
+			if _, ok := g.testOnly[n]; ok {
+				if !prod[n] {
+					m.testOnly[n] = struct{}{}
+				}
+			} else {
+				prod[n] = true
+				delete(m.testOnly, n)
+			}
+		}
+		union(m.edges, g.edges)
+		union(m.prodEdges, g.prodEdges)
+		for e, n := range g.counts {
+			m.counts[e] = max(m.counts[e], n)
+		}
+		for n, c := range g.pkgCounts {
+			m.pkgCounts[n] = max(m.pkgCounts[n], c)
+		}
+		for n, c := range g.mainUsage {
+			m.mainUsage[n] += c
+		}
+		maps.Copy(m.versions, g.versions)
+		maps.Copy(m.replaces, g.replaces)
+		maps.Copy(m.localReplaces, g.localReplaces)
+		maps.Copy(m.testOnlyPkgs, g.testOnlyPkgs)
+		maps.Copy(m.broken, g.broken)
+		m.loadErrors = append(m.loadErrors, g.loadErrors...)
+		// The merged graph has no dependencies
+		// only if none of the graphs have any.
+		m.noDeps = (i == 0 || m.noDeps) && g.noDeps
+		maps.Copy(m.deprecated, g.deprecated)
+		maps.Copy(m.retracted, g.retracted)
+		maps.Copy(m.dirs, g.dirs)
+		maps.Copy(m.conflicts, g.conflicts)
+		m.overlays = append(m.overlays, classOverlay{
+			name:  fmt.Sprintf("main%d", i),
+			style: fmt.Sprintf("fill:%s,stroke:#333,stroke-width:2px", mainColors[i%len(mainColors)]),
+			nodes: map[string]struct{}{g.mainMod: {}},
+		})
+	}
+	// Loads often fail in the same way, so keep each error
+	// only once, in the order that loadErrors gives them.
+	slices.Sort(m.loadErrors)
+	m.loadErrors = slices.Compact(m.loadErrors)
+	// A main module is never test-only.
+	for i, g := range gs {
+		delete(m.testOnly, g.mainMod)
+		if i > 0 {
+			m.otherMains = append(m.otherMains, g.mainMod)
+		}
+	}
+	return m
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestMergeGraphs(t *testing.T) {
+	g1 := edgeGraph([2]string{"example.com/one", "example.com/shared"}, [2]string{"example.com/one", "example.com/t1"})
+	g1.mainMod = "example.com/one"
+	g1.testOnly = map[string]struct{}{"example.com/shared": {}, "example.com/t1": {}}
+	g2 := edgeGraph([2]string{"example.com/two", "example.com/shared"}, [2]string{"example.com/two", "example.com/one"})
+	g2.mainMod = "example.com/two"
+	g2.testOnly = map[string]struct{}{"example.com/one": {}}
+	m := mergeGraphs([]*graph{g1, g2})
+	if m.mainMod != "example.com/one" {
+		t.Errorf("got main module %q, want the first graph's", m.mainMod)
+	}
+	if want := []string{"example.com/two"}; !reflect.DeepEqual(m.otherMains, want) {
+		t.Errorf("got other main modules %q, want %q", m.otherMains, want)
+	}
+	// shared is test-only only in the first graph, and one, although
+	// test-only in the second graph, is a main module.
+	if got, want := sortedKeys(m.testOnly), []string{"example.com/t1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got test-only modules %q, want %q", got, want)
+	}
+	if got, want := len(m.nodes), 4; got != want {
+		t.Errorf("got %d nodes, want %d", got, want)
+	}
+}
+
+func TestMulti(t *testing.T) {
+	out := mustRun(t, fixture(t, "fx/main"), "-multi", fixture(t, "fx/main")+","+fixture(t, "fx/main2"), "-format", "porcelain")
+	for _, line := range []string{
+		"N\texample.com/main\tmainModule\t\n",
+		"N\texample.com/main2\tregularDep\t\n",
+		"N\texample.com/x\ttestOnlyDep\tv1.0.0\n",
+		"E\texample.com/main2\texample.com/b\n",
+		"E\texample.com/main\texample.com/a\n",
+	} {
+		if !strings.Contains(out, line) {
+			t.Errorf("merged graph has no line %q:\n%s", line, out)
+		}
+	}
+}
+
+func TestMultiKeepsLoadProblems(t *testing.T) {
+	r := runMain(t, fixture(t, "fx/main"), nil, "-multi", fixture(t, "fx/main3")+","+fixture(t, "fx/main2"), "-broken", "-quiet-errors")
+	if r.failed {
+		t.Fatalf("-multi with -broken failed:\n%s", r.stderr)
+	}
+	if !strings.Contains(r.stderr, "broken: no package in example.com/bad loaded without errors\n") {
+		t.Errorf("broken module is not logged:\n%s", r.stderr)
+	}
+	nodes := mermaidNodes(r.stdout)
+	if want := fmt.Sprintf("class N%d brokenDep;\n", slices.Index(nodes, "example.com/bad")); !strings.Contains(r.stdout, want) {
+		t.Errorf("output does not contain %q:\n%s", want, r.stdout)
+	}
+	// Both loads of example.com/main3 report the same error.
+	if got := strings.Count(r.stdout, "%% -: found packages bad (bad.go) and other (other.go)"); got != 1 {
+		t.Errorf("got the load error %d times in the output, want once:\n%s", got, r.stdout)
+	}
+}
+
+func TestMultiNoDeps(t *testing.T) {
+	root := t.TempDir()
+	var dirs []string
+	for _, name := range []string{"one", "two"} {
+		dir := filepath.Join(root, name)
+		writeFile(t, filepath.Join(dir, "go.mod"), "module example.com/"+name+"\n\ngo 1.22\n")
+		writeFile(t, filepath.Join(dir, name+".go"), "package "+name+"\n")
+		dirs = append(dirs, dir)
+	}
+	r := runMain(t, dirs[0], nil, "-multi", strings.Join(dirs, ","))
+	if r.failed || !strings.Contains(r.stderr, "note: example.com/one, example.com/two have no external module dependencies\n") {
+		t.Errorf("got failed=%v and log:\n%s", r.failed, r.stderr)
+	}
+	if !strings.Contains(r.stdout, "%% no external module dependencies\n") {
+		t.Errorf("output has no comment saying there are no dependencies:\n%s", r.stdout)
+	}
+
+	g1 := edgeGraph()
+	g1.mainMod, g1.noDeps = "example.com/one", true
+	g2 := edgeGraph([2]string{"example.com/two", "example.com/dep"})
+	g2.mainMod = "example.com/two"
+	if mergeGraphs([]*graph{g1, g2}).noDeps {
+		t.Errorf("merged graph has no dependencies although one of its graphs has")
+	}
+}
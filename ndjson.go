@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"time"
+)
+
+// ndjsonEvent is a line of the ndjson-events format. Every event has
+// the same envelope of timestamp, event type and main module, with the
+// remaining fields depending on the type.
+type ndjsonEvent struct {
+	TS    string `json:"ts"`
+	Event string `json:"event"`
+	Main  string `json:"main"`
+
+	// Fields of module events.
+	Path     string `json:"path,omitempty"`
+	Class    string `json:"class,omitempty"`
+	Version  string `json:"version,omitempty"`
+	TestOnly *bool  `json:"testOnly,omitempty"`
+
+	// Fields of edge events.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	Test *bool  `json:"test,omitempty"`
+}
+
+// writeNDJSONEvents writes g as newline-delimited JSON events for log
+// processing pipelines: a module event for each module, sorted by path,
+// and then an edge event for each edge, sorted by source and target.
+// All the events of one run share the time at which writing began, so
+// that a snapshot can be picked out of a stream by its timestamp.
+func writeNDJSONEvents(out io.Writer, g *graph) {
+	ts := time.Now().UTC().Format(time.RFC3339Nano)
+	enc := json.NewEncoder(out)
+	emit := func(e ndjsonEvent) {
+		e.TS, e.Main = ts, g.mainMod
+		if err := enc.Encode(e); err != nil {
+			log.Fatalf("cannot write event: %v", err)
+		}
+	}
+	cg := canonicalize(g)
+	for _, n := range cg.nodes {
+		emit(ndjsonEvent{
+			Event:    "module",
+			Path:     n.path,
+			Class:    n.class,
+			Version:  n.version,
+			TestOnly: &n.testOnly,
+		})
+	}
+	for _, e := range cg.edges {
+		emit(ndjsonEvent{
+			Event: "edge",
+			From:  e.from,
+			To:    e.to,
+			Test:  &e.test,
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNDJSONEvents(t *testing.T) {
+	out := mustRun(t, fixture(t, "fx/main"), "-format", "ndjson-events")
+	var ts string
+	for _, line := range strings.SplitAfter(strings.TrimSuffix(out, "\n"), "\n") {
+		var e ndjsonEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("bad event %q: %v", line, err)
+		}
+		if ts == "" {
+			ts = e.TS
+			if _, err := time.Parse(time.RFC3339Nano, ts); err != nil {
+				t.Errorf("bad timestamp: %v", err)
+			}
+		} else if e.TS != ts {
+			t.Errorf("event %q has timestamp %q, want %q", line, e.TS, ts)
+		}
+	}
+	checkGolden(t, "fx.ndjson", eventTime.ReplaceAllString(out, `"ts":""`))
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// mermaidKeywords holds words with a meaning of their own in
+// mermaid flowchart source, which cannot be used as node identifiers.
+var mermaidKeywords = map[string]bool{
+	"end":       true,
+	"graph":     true,
+	"flowchart": true,
+	"subgraph":  true,
+	"direction": true,
+	"click":     true,
+	"style":     true,
+	"class":     true,
+	"classDef":  true,
+	"linkStyle": true,
+	"call":      true,
+	"href":      true,
+}
+
+// nodeIDs returns the mermaid identifier of each of the given nodes,
+// which must be in declaration order, according to the -node-id flag:
+// N followed by the node's position for "index", the node's path with
+// every character that is not a letter, digit or underscore replaced
+// by an underscore for "path", or N followed by a prefix of the hash
+// of the path for "hash". The latter two do not change when other
+// nodes are added or removed, so they keep diffs of the output small.
+// Should two nodes map to the same identifier, the later one in
+// sorted order gets a numeric suffix.
+func nodeIDs(nodes []string) map[string]string {
+	ids := make(map[string]string)
+	if *flagNodeID == "index" {
+		for i, n := range nodes {
+			ids[n] = fmt.Sprintf("N%d", i)
+		}
+		return ids
+	}
+	used := make(map[string]bool)
+	for _, n := range sortedKeys(sliceSet(nodes)) {
+		var id string
+		if *flagNodeID == "hash" {
+			id = fmt.Sprintf("N%x", sha256.Sum256([]byte(n)))[:9]
+		} else {
+			id = strings.Map(func(r rune) rune {
+				if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) || r == '_' {
+					return r
+				}
+				return '_'
+			}, n)
+			if id == "" || unicode.IsDigit(rune(id[0])) || mermaidKeywords[id] {
+				id = "M_" + id
+			}
+		}
+		base := id
+		for i := 2; used[id]; i++ {
+			id = fmt.Sprintf("%s_%d", base, i)
+		}
+		used[id] = true
+		ids[n] = id
+	}
+	return ids
+}
+
+// sliceSet returns the elements of s as a set.
+func sliceSet(s []string) map[string]struct{} {
+	m := make(map[string]struct{})
+	for _, x := range s {
+		m[x] = struct{}{}
+	}
+	return m
+}
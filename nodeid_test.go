@@ -0,0 +1,53 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestNodeIDs(t *testing.T) {
+	nodes := []string{"example.com/b", "example.com/a", "example_com_a", "end", "1x", "ünïcode"}
+
+	setFlag(t, "node-id", "index")
+	want := map[string]string{
+		"example.com/b": "N0",
+		"example.com/a": "N1",
+		"example_com_a": "N2",
+		"end":           "N3",
+		"1x":            "N4",
+		"ünïcode":       "N5",
+	}
+	if got := nodeIDs(nodes); !reflect.DeepEqual(got, want) {
+		t.Errorf("index: got %v, want %v", got, want)
+	}
+
+	setFlag(t, "node-id", "path")
+	want = map[string]string{
+		"example.com/b": "example_com_b",
+		"example.com/a": "example_com_a",
+		// This sorts after example.com/a, so it takes the suffix.
+		"example_com_a": "example_com_a_2",
+		"end":           "M_end",
+		"1x":            "M_1x",
+		"ünïcode":       "_n_code",
+	}
+	if got := nodeIDs(nodes); !reflect.DeepEqual(got, want) {
+		t.Errorf("path: got %v, want %v", got, want)
+	}
+
+	setFlag(t, "node-id", "hash")
+	ids := nodeIDs(nodes)
+	again := nodeIDs(nodes[:2])
+	hashID := regexp.MustCompile(`^N[0-9a-f]{8}(_[0-9]+)?$`)
+	for _, n := range nodes {
+		if !hashID.MatchString(ids[n]) {
+			t.Errorf("hash: got identifier %q for %q", ids[n], n)
+		}
+	}
+	for _, n := range nodes[:2] {
+		if again[n] != ids[n] {
+			t.Errorf("hash: identifier of %q changed from %q to %q when other nodes were removed", n, ids[n], again[n])
+		}
+	}
+}
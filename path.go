@@ -0,0 +1,196 @@
+package main
+
+import (
+	"container/list"
+	"log"
+	"sort"
+	"strings"
+)
+
+// criticalPathStyle is the style used to highlight the
+// nodes and edges of the longest dependency chain.
+const criticalPathStyle = "stroke:#e67e00,stroke-width:4px"
+
+// longestPath returns the longest chain of dependencies in g starting
+// at the main module. Each element holds the members of one strongly
+// connected component, so that a cycle counts as a single step and
+// the chain is always finite. Ties are broken in favor of the
+// component whose first member sorts first.
+func (g *graph) longestPath() [][]string {
+	// Tarjan's algorithm yields components in reverse
+	// topological order, so each component's successors
+	// have been measured by the time it is reached.
+	sccs := stronglyConnected(g.edges)
+	comp := make(map[string]int)
+	for i, scc := range sccs {
+		sort.Strings(scc)
+		for _, n := range scc {
+			comp[n] = i
+		}
+	}
+	depth := make([]int, len(sccs))
+	next := make([]int, len(sccs))
+	for i, scc := range sccs {
+		depth[i], next[i] = 1, -1
+		for _, from := range scc {
+			for _, to := range sortedKeys(g.edges[from]) {
+				c := comp[to]
+				if c == i {
+					continue
+				}
+				if d := depth[c] + 1; d > depth[i] || d == depth[i] && next[i] >= 0 && sccs[c][0] < sccs[next[i]][0] {
+					depth[i], next[i] = d, c
+				}
+			}
+		}
+	}
+	start, ok := comp[g.mainMod]
+	if !ok {
+		return nil
+	}
+	var path [][]string
+	for c := start; c >= 0; c = next[c] {
+		path = append(path, sccs[c])
+	}
+	return path
+}
+
+// showLongestPath logs the longest dependency chain in g and
+// highlights its nodes and the edges between them.
+func showLongestPath(g *graph) {
+	path := g.longestPath()
+	if len(path) == 0 {
+		log.Printf("longest path: main module not in graph")
+		return
+	}
+	steps := make([]string, len(path))
+	nodes := make(map[string]struct{})
+	for i, scc := range path {
+		steps[i] = scc[0]
+		if len(scc) > 1 {
+			steps[i] = "{" + strings.Join(scc, ", ") + "}"
+		}
+		for _, n := range scc {
+			nodes[n] = struct{}{}
+		}
+		if i == 0 {
+			continue
+		}
+		for _, from := range path[i-1] {
+			for _, to := range scc {
+				if _, ok := g.edges[from][to]; ok {
+					g.edgeStyles[edge{from, to}] = criticalPathStyle
+				}
+			}
+		}
+	}
+	log.Printf("longest path (%d steps): %s", len(path)-1, strings.Join(steps, " -> "))
+	g.overlays = append(g.overlays, classOverlay{
+		name:  "criticalPath",
+		style: criticalPathStyle,
+		nodes: nodes,
+	})
+}
+
+// maxFocusPaths bounds the number of paths that
+// focusPaths enumerates, as there can be exponentially many.
+const maxFocusPaths = 1000
+
+// focusPathStyle is the style used to highlight
+// the nodes and edges on the paths chosen by -focus-path.
+const focusPathStyle = "stroke:#0a0,stroke-width:4px"
+
+// focusPaths returns the simple paths from src to dst in g,
+// enumerated in name order, and reports whether the enumeration
+// stopped early because there were more than maxFocusPaths of them.
+// Only nodes from which dst can be reached are explored, so that the
+// time taken depends on the paths found rather than on the size of
+// the rest of the graph.
+func (g *graph) focusPaths(src, dst string) (paths [][]string, truncated bool) {
+	// Find the nodes that can reach dst by searching
+	// backwards from it along the reversed edges.
+	preds := make(map[string][]string)
+	for from, tos := range g.edges {
+		for to := range tos {
+			preds[to] = append(preds[to], from)
+		}
+	}
+	reaches := map[string]bool{dst: true}
+	q := list.New()
+	q.PushBack(dst)
+	for q.Len() > 0 {
+		n := q.Remove(q.Front()).(string)
+		for _, from := range preds[n] {
+			if !reaches[from] {
+				reaches[from] = true
+				q.PushBack(from)
+			}
+		}
+	}
+	if !reaches[src] {
+		return nil, false
+	}
+	onPath := make(map[string]bool)
+	var path []string
+	var visit func(n string) bool
+	visit = func(n string) bool {
+		path = append(path, n)
+		onPath[n] = true
+		defer func() {
+			path = path[:len(path)-1]
+			onPath[n] = false
+		}()
+		if n == dst {
+			if len(paths) == maxFocusPaths {
+				return false
+			}
+			paths = append(paths, append([]string(nil), path...))
+			return true
+		}
+		for _, to := range sortedKeys(g.edges[n]) {
+			if reaches[to] && !onPath[to] && !visit(to) {
+				return false
+			}
+		}
+		return true
+	}
+	truncated = !visit(src)
+	return paths, truncated
+}
+
+// showFocusPaths highlights the nodes and edges on every path from src
+// to dst in g. If only is true, all other nodes are removed. It is
+// a fatal error if there is no such path.
+func showFocusPaths(g *graph, src, dst string, only bool) {
+	for _, n := range []string{src, dst} {
+		if _, ok := g.nodes[n]; !ok {
+			log.Fatalf("-focus-path: module %s not found in graph", n)
+		}
+	}
+	paths, truncated := g.focusPaths(src, dst)
+	if len(paths) == 0 {
+		log.Fatalf("-focus-path: no path from %s to %s", src, dst)
+	}
+	if truncated {
+		log.Printf("warning: -focus-path: showing only the first %d paths from %s to %s", len(paths), src, dst)
+	} else {
+		log.Printf("note: %d paths from %s to %s", len(paths), src, dst)
+	}
+	nodes := make(map[string]struct{})
+	for _, p := range paths {
+		for i, n := range p {
+			nodes[n] = struct{}{}
+			if i > 0 {
+				g.edgeStyles[edge{p[i-1], n}] = focusPathStyle
+			}
+		}
+	}
+	if only {
+		g.keepNodes(nodes)
+	}
+	g.overlays = append(g.overlays, classOverlay{
+		name:  "focusPath",
+		style: focusPathStyle,
+		nodes: nodes,
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// edgeGraph returns a graph holding just the given edges,
+// each of which is a pair of module paths.
+func edgeGraph(edges ...[2]string) *graph {
+	g := &graph{
+		nodes: make(map[string]struct{}),
+		edges: make(map[string]map[string]struct{}),
+	}
+	for _, e := range edges {
+		g.nodes[e[0]] = struct{}{}
+		g.nodes[e[1]] = struct{}{}
+		if g.edges[e[0]] == nil {
+			g.edges[e[0]] = make(map[string]struct{})
+		}
+		g.edges[e[0]][e[1]] = struct{}{}
+	}
+	return g
+}
+
+func TestFocusPaths(t *testing.T) {
+	edges := [][2]string{
+		{"src", "p"}, {"p", "dst"},
+		{"src", "q"}, {"q", "dst"},
+		{"src", "a0"},
+	}
+	// Add a ladder of diamonds below a0 that never leads to dst
+	// but holds 2^40 paths, which exploring would never finish.
+	for i := 0; i < 40; i++ {
+		a, next := fmt.Sprint("a", i), fmt.Sprint("a", i+1)
+		edges = append(edges,
+			[2]string{a, "b" + a}, [2]string{"b" + a, next},
+			[2]string{a, "c" + a}, [2]string{"c" + a, next},
+		)
+	}
+	paths, truncated := edgeGraph(edges...).focusPaths("src", "dst")
+	want := [][]string{{"src", "p", "dst"}, {"src", "q", "dst"}}
+	if !reflect.DeepEqual(paths, want) || truncated {
+		t.Errorf("got paths %q (truncated %v), want %q", paths, truncated, want)
+	}
+	if paths, _ := edgeGraph(edges...).focusPaths("dst", "src"); len(paths) != 0 {
+		t.Errorf("got paths %q from dst to src, want none", paths)
+	}
+}
+
+func TestLongestPath(t *testing.T) {
+	g := edgeGraph(
+		[2]string{"main", "a"}, [2]string{"a", "b"},
+		// c and d form a cycle, which counts as one step,
+		// so that this route is just one step longer.
+		[2]string{"main", "c"}, [2]string{"c", "d"}, [2]string{"d", "c"}, [2]string{"d", "e"}, [2]string{"e", "h"},
+	)
+	g.mainMod = "main"
+	got := g.longestPath()
+	want := [][]string{{"main"}, {"c", "d"}, {"e"}, {"h"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got longest path %q, want %q", got, want)
+	}
+
+	g = edgeGraph([2]string{"main", "z"}, [2]string{"z", "y"}, [2]string{"main", "b"}, [2]string{"b", "y2"})
+	g.mainMod = "main"
+	if got, want := g.longestPath(), [][]string{{"main"}, {"b"}, {"y2"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got longest path %q, want the tie broken by name as %q", got, want)
+	}
+	g.mainMod = "missing"
+	if got := g.longestPath(); got != nil {
+		t.Errorf("got path %q without the main module in the graph", got)
+	}
+}
+
+func TestLongestPathFlag(t *testing.T) {
+	r := runMain(t, fixture(t, "fx/main"), nil, "-longest-path")
+	if r.failed {
+		t.Fatalf("-longest-path failed:\n%s", r.stderr)
+	}
+	if want := "longest path (3 steps): example.com/main -> example.com/a -> example.com/c -> example.com/x"; !strings.Contains(r.stderr, want) {
+		t.Errorf("standard error does not contain %q:\n%s", want, r.stderr)
+	}
+	if !strings.Contains(r.stdout, "classDef criticalPath "+criticalPathStyle) {
+		t.Errorf("longest path not highlighted:\n%s", r.stdout)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+)
+
+// readPatternsFile reads package patterns from the named file,
+// or from standard input if file is "-", as described by readPatterns.
+func readPatternsFile(file string) ([]string, error) {
+	if file == "-" {
+		return readPatterns(os.Stdin)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return readPatterns(f)
+}
+
+// readPatterns reads package patterns, one per line, from r.
+// Blank lines and lines starting with # are ignored.
+func readPatterns(r io.Reader) ([]string, error) {
+	var patterns []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadPatterns(t *testing.T) {
+	tests := []struct {
+		name, in string
+		want     []string
+	}{
+		{"empty", "", nil},
+		{"one", "./...\n", []string{"./..."}},
+		{"no final newline", "example.com/a\nexample.com/b", []string{"example.com/a", "example.com/b"}},
+		{"comments", "# generated\nexample.com/a\n  # indented\n", []string{"example.com/a"}},
+		{"blank lines", "\n\nexample.com/a\n \t \nexample.com/b/...\n\n", []string{"example.com/a", "example.com/b/..."}},
+		{"surrounding space", "  example.com/a\t\r\n", []string{"example.com/a"}},
+		{"hash within", "example.com/a#b\n", []string{"example.com/a#b"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := readPatterns(strings.NewReader(test.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadPatternsFileStdin(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "patterns")
+	writeFile(t, file, "# from stdin\nexample.com/a\n\nexample.com/b\n")
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stdin := os.Stdin
+	defer func() {
+		os.Stdin = stdin
+	}()
+	os.Stdin = f
+	got, err := readPatternsFile("-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"example.com/a", "example.com/b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// writePorcelain writes g in the porcelain format, which is intended
+// for use by scripts and is guaranteed not to change incompatibly.
+//
+// The first line is a header identifying the format version:
+//
+//	# porcelain 1
+//
+// It is followed by one line per node, in path order:
+//
+//	N<TAB>path<TAB>class<TAB>version
+//
+// where class is one of mainModule, testOnlyDep or regularDep,
+// and version is empty for the main module or when unknown.
+// Then come the edges, one per line, ordered by from and then to:
+//
+//	E<TAB>from<TAB>to
+//
+// Any new kinds of record will use a different initial field,
+// so consumers should ignore lines they do not recognize.
+func writePorcelain(out io.Writer, g *graph) {
+	fmt.Fprintf(out, "# porcelain 1\n")
+	cg := canonicalize(g)
+	for _, n := range cg.nodes {
+		fmt.Fprintf(out, "N\t%s\t%s\t%s\n", n.path, n.class, n.version)
+	}
+	for _, e := range cg.edges {
+		fmt.Fprintf(out, "E\t%s\t%s\n", e.from, e.to)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// startProfiles starts CPU profiling if -cpuprofile has been given.
+func startProfiles() {
+	if *flagCPUProfile == "" {
+		return
+	}
+	f, err := os.Create(*flagCPUProfile)
+	if err != nil {
+		log.Fatalf("cannot create CPU profile: %v", err)
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		log.Fatalf("cannot start CPU profile: %v", err)
+	}
+}
+
+// stopProfiles stops any CPU profile started by startProfiles and
+// writes a heap profile if -memprofile has been given. It must be
+// called before exiting.
+func stopProfiles() {
+	if *flagCPUProfile != "" {
+		pprof.StopCPUProfile()
+	}
+	if *flagMemProfile == "" {
+		return
+	}
+	f, err := os.Create(*flagMemProfile)
+	if err != nil {
+		log.Fatalf("cannot create memory profile: %v", err)
+	}
+	defer f.Close()
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		log.Fatalf("cannot write memory profile: %v", err)
+	}
+}
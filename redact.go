@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// redact replaces the path of each module in g that matches pattern
+// with a token of the form internal-N, numbered in path order so that
+// the same graph is always redacted the same way. The structure of the
+// graph is unchanged. Every map keyed by node is renamed, and any
+// mention of a redacted path in the text attached to the graph, such
+// as tooltips, notes and load errors, is replaced by its token, so it
+// should be the last change made to g before it is written. The mapping
+// from token to path is printed to standard error for reference.
+func (g *graph) redact(pattern *regexp.Regexp) {
+	names := make(map[string]string)
+	for _, n := range sortedKeys(g.nodes) {
+		if pattern.MatchString(n) {
+			token := fmt.Sprintf("internal-%d", len(names)+1)
+			names[n] = token
+			fmt.Fprintf(os.Stderr, "%s\t%s\n", token, n)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+	rename := func(n string) string {
+		if token, ok := names[n]; ok {
+			return token
+		}
+		return n
+	}
+	// The replacer prefers the earlier of two matches at the same
+	// place, so longer paths come first to stop a path being
+	// partly replaced by a token for one of its prefixes.
+	paths := sortedKeys(names)
+	sort.SliceStable(paths, func(i, j int) bool {
+		return len(paths[i]) > len(paths[j])
+	})
+	var pairs []string
+	for _, n := range paths {
+		pairs = append(pairs, n, names[n])
+	}
+	replacer := strings.NewReplacer(pairs...)
+	renameSet := func(m map[string]struct{}) map[string]struct{} {
+		res := make(map[string]struct{})
+		for n := range m {
+			res[rename(n)] = struct{}{}
+		}
+		return res
+	}
+	renameEdges := func(m map[string]map[string]struct{}) map[string]map[string]struct{} {
+		res := make(map[string]map[string]struct{})
+		for from, tos := range m {
+			res[rename(from)] = renameSet(tos)
+		}
+		return res
+	}
+	renameValues := func(m map[string]string) map[string]string {
+		res := make(map[string]string)
+		for n, v := range m {
+			res[rename(n)] = replacer.Replace(v)
+		}
+		return res
+	}
+	renameEdgeValues := func(m map[edge]string) map[edge]string {
+		res := make(map[edge]string)
+		for e, v := range m {
+			res[edge{rename(e.from), rename(e.to)}] = replacer.Replace(v)
+		}
+		return res
+	}
+	renameCounts := func(m map[string]int) map[string]int {
+		res := make(map[string]int)
+		for n, c := range m {
+			res[rename(n)] = c
+		}
+		return res
+	}
+	renameList := func(l []string) []string {
+		res := make([]string, len(l))
+		for i, s := range l {
+			res[i] = replacer.Replace(s)
+		}
+		return res
+	}
+	counts := make(map[edge]int)
+	for e, c := range g.counts {
+		counts[edge{rename(e.from), rename(e.to)}] = c
+	}
+	// Replacements may themselves reveal the hidden
+	// paths, so drop those of redacted modules.
+	replaces := make(map[string]string)
+	for n, r := range g.replaces {
+		if _, ok := names[n]; !ok {
+			replaces[n] = replacer.Replace(r)
+		}
+	}
+	// The short name of a redacted module is derived
+	// from its path, so it is shown as its token instead.
+	shortNames := make(map[string]string)
+	for n, short := range g.shortNames {
+		if _, ok := names[n]; !ok {
+			shortNames[n] = short
+		}
+	}
+	notes := make(map[string][]string)
+	for n, l := range g.notes {
+		notes[rename(n)] = renameList(l)
+	}
+	conflicts := make(map[string]map[string][]string)
+	for n, byVersion := range g.conflicts {
+		res := make(map[string][]string)
+		for v, l := range byVersion {
+			res[v] = renameList(l)
+		}
+		conflicts[rename(n)] = res
+	}
+	g.mainMod = rename(g.mainMod)
+	for i, n := range g.otherMains {
+		g.otherMains[i] = rename(n)
+	}
+	for i, n := range g.hidden {
+		g.hidden[i] = rename(n)
+	}
+	g.nodes = renameSet(g.nodes)
+	g.testOnly = renameSet(g.testOnly)
+	g.localReplaces = renameSet(g.localReplaces)
+	g.testOnlyPkgs = renameSet(g.testOnlyPkgs)
+	g.broken = renameSet(g.broken)
+	g.edges = renameEdges(g.edges)
+	g.prodEdges = renameEdges(g.prodEdges)
+	g.counts = counts
+	g.edgeLabels = renameEdgeValues(g.edgeLabels)
+	g.edgeStyles = renameEdgeValues(g.edgeStyles)
+	for i, e := range g.extraEdges {
+		g.extraEdges[i] = labeledEdge{edge{rename(e.from), rename(e.to)}, e.label}
+	}
+	g.pkgCounts = renameCounts(g.pkgCounts)
+	g.mainUsage = renameCounts(g.mainUsage)
+	g.replaces = replaces
+	g.shortNames = shortNames
+	g.notes = notes
+	g.conflicts = conflicts
+	g.versions = renameValues(g.versions)
+	g.deprecated = renameValues(g.deprecated)
+	g.retracted = renameValues(g.retracted)
+	g.dirs = renameValues(g.dirs)
+	g.tooltips = renameValues(g.tooltips)
+	g.loadErrors = renameList(g.loadErrors)
+	for i := range g.overlays {
+		g.overlays[i].nodes = renameSet(g.overlays[i].nodes)
+	}
+	for i := range g.clusters {
+		g.clusters[i].title = replacer.Replace(g.clusters[i].title)
+		g.clusters[i].nodes = renameSet(g.clusters[i].nodes)
+	}
+}
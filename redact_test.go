@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	const secret = "corp.example/secret"
+	g := edgeGraph(
+		[2]string{"example.com/main", secret},
+		[2]string{secret, "example.com/lib"},
+		[2]string{"example.com/main", secret + "/v2"},
+	)
+	g.mainMod = "example.com/main"
+	g.testOnly = map[string]struct{}{secret: {}}
+	g.prodEdges = map[string]map[string]struct{}{"example.com/main": {secret: {}}}
+	g.versions = map[string]string{secret: "v1.0.0"}
+	g.replaces = map[string]string{secret: "../secret", "example.com/lib": "../" + secret}
+	g.localReplaces = map[string]struct{}{secret: {}}
+	g.testOnlyPkgs = map[string]struct{}{secret: {}}
+	g.broken = map[string]struct{}{secret: {}}
+	g.counts = map[edge]int{{"example.com/main", secret}: 2}
+	g.edgeLabels = map[edge]string{{"example.com/main", secret}: "(test)"}
+	g.edgeStyles = map[edge]string{{secret, "example.com/lib"}: "stroke:red"}
+	g.extraEdges = []labeledEdge{{edge{"example.com/main", secret}, "tool"}}
+	g.tooltips = map[string]string{secret: secret, "example.com/lib": "Used by " + secret}
+	g.notes = map[string][]string{secret: {"v1.0.0"}, "example.com/lib": {"via " + secret + "/v2"}}
+	g.shortNames = map[string]string{secret: "c.e/secret"}
+	g.loadErrors = []string{secret + "/pkg: no Go files"}
+	g.conflicts = map[string]map[string][]string{secret: {"v1.0.0": {"example.com/main imports " + secret}}}
+	g.hidden = []string{secret}
+	g.overlays = []classOverlay{{name: "x", nodes: map[string]struct{}{secret: {}}}}
+	g.clusters = []cluster{{title: secret, nodes: map[string]struct{}{secret: {}}}}
+
+	g.redact(regexp.MustCompile(`^corp\.example/`))
+
+	if got := fmt.Sprintf("%+v", *g); strings.Contains(got, "corp.example") {
+		t.Errorf("redacted graph still mentions corp.example: %s", got)
+	}
+	if _, ok := g.edges["example.com/main"]["internal-1"]; !ok {
+		t.Errorf("no edge to internal-1 in %v", g.edges)
+	}
+	if got, want := g.notes["example.com/lib"][0], "via internal-2"; got != want {
+		t.Errorf("got note %q, want %q", got, want)
+	}
+	if got, want := g.loadErrors[0], "internal-1/pkg: no Go files"; got != want {
+		t.Errorf("got load error %q, want %q", got, want)
+	}
+	if _, ok := g.localReplaces["internal-1"]; !ok {
+		t.Errorf("local replacement not renamed: %v", g.localReplaces)
+	}
+}
+
+func TestRedactInvalidPattern(t *testing.T) {
+	// The pattern is checked before anything is loaded,
+	// so this fails the same way outside any module.
+	r := runMain(t, t.TempDir(), nil, "-redact", "(")
+	if want := "invalid -redact pattern: error parsing regexp"; !r.failed || !strings.Contains(r.stderr, want) {
+		t.Errorf("-redact ( did not fail with %q:\n%s", want, r.stderr)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// moduleReport is the JSON form of the report written
+// by -report for a single module.
+type moduleReport struct {
+	Path     string `json:"path"`
+	Version  string `json:"version,omitempty"`
+	Class    string `json:"class"`
+	TestOnly bool   `json:"testOnly"`
+	// MainImporters holds the number of non-test packages
+	// in the main module that import the module.
+	MainImporters int      `json:"mainImporters"`
+	Imports       []string `json:"imports"`
+	ImportedBy    []string `json:"importedBy"`
+}
+
+// writeReports writes a JSON report describing each module in g to
+// its own file in dir, named after the module path.
+func writeReports(dir string, g *graph) {
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		log.Fatal(err)
+	}
+	importedBy := make(map[string][]string)
+	for _, from := range sortedKeys(g.edges) {
+		for to := range g.edges[from] {
+			importedBy[to] = append(importedBy[to], from)
+		}
+	}
+	for _, n := range sortedKeys(g.nodes) {
+		_, testOnly := g.testOnly[n]
+		r := moduleReport{
+			Path:          n,
+			Version:       g.versions[n],
+			Class:         g.class(n),
+			TestOnly:      testOnly && n != g.mainMod,
+			MainImporters: g.mainUsage[n],
+			Imports:       sortedKeys(g.edges[n]),
+			ImportedBy:    importedBy[n],
+		}
+		if r.ImportedBy == nil {
+			r.ImportedBy = []string{}
+		}
+		data, err := json.MarshalIndent(r, "", "\t")
+		if err != nil {
+			log.Fatalf("cannot encode report: %v", err)
+		}
+		file := filepath.Join(dir, fileNameEscaper.Replace(n)+".json")
+		if err := os.WriteFile(file, append(data, '\n'), 0o666); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "report")
+	mustRun(t, fixture(t, "fx/main"), "-report", dir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 10 {
+		t.Errorf("got %d reports, want one for each of the 10 modules", len(entries))
+	}
+	for _, want := range []moduleReport{{
+		Path:          "example.com/a",
+		Version:       "v1.0.0",
+		Class:         "regularDep",
+		MainImporters: 1,
+		Imports:       []string{"example.com/c", "example.com/f"},
+		ImportedBy:    []string{"example.com/main"},
+	}, {
+		Path:       "example.com/g",
+		Version:    "v1.0.0",
+		Class:      "testOnlyDep",
+		TestOnly:   true,
+		Imports:    []string{},
+		ImportedBy: []string{"example.com/f"},
+	}, {
+		// The lists are empty rather than null.
+		Path:       "example.com/main",
+		Class:      "mainModule",
+		Imports:    []string{"example.com/a", "example.com/b", "example.com/t"},
+		ImportedBy: []string{},
+	}} {
+		data, err := os.ReadFile(filepath.Join(dir, fileNameEscaper.Replace(want.Path)+".json"))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		var got moduleReport
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Errorf("%s: %v", want.Path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got report %+v, want %+v", got, want)
+		}
+	}
+}
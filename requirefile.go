@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/tools/go/packages"
+)
+
+// readRequireFile returns the graph described by the require directives
+// of the named go.mod file: an edge from the main module to each
+// directly required module. Nothing is compiled, so test-only modules
+// cannot be distinguished and none are marked as such. Modules marked
+// "// indirect" are annotated in their labels.
+func readRequireFile(file string) (*graph, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	f, err := modfile.Parse(file, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	if f.Module == nil {
+		return nil, fmt.Errorf("%s: no module directive", file)
+	}
+	main := f.Module.Mod.Path
+	g := &graph{
+		mainMod:    main,
+		nodes:      map[string]struct{}{main: {}},
+		edges:      make(map[string]map[string]struct{}),
+		testOnly:   make(map[string]struct{}),
+		versions:   map[string]string{main: ""},
+		replaces:   make(map[string]string),
+		pkgCounts:  make(map[string]int),
+		prodEdges:  make(map[string]map[string]struct{}),
+		counts:     make(map[edge]int),
+		edgeStyles: make(map[edge]string),
+		edgeLabels: make(map[edge]string),
+		notes:      make(map[string][]string),
+		tooltips:   make(map[string]string),
+
+		localReplaces: make(map[string]struct{}),
+		testOnlyPkgs:  make(map[string]struct{}),
+	}
+	for _, r := range f.Require {
+		path := r.Mod.Path
+		g.nodes[path] = struct{}{}
+		g.versions[path] = r.Mod.Version
+		if g.edges[main] == nil {
+			g.edges[main] = make(map[string]struct{})
+			g.prodEdges[main] = make(map[string]struct{})
+		}
+		g.edges[main][path] = struct{}{}
+		g.prodEdges[main][path] = struct{}{}
+		if r.Indirect {
+			g.notes[path] = append(g.notes[path], "(indirect)")
+		}
+	}
+	for _, r := range f.Replace {
+		if _, ok := g.nodes[r.Old.Path]; ok {
+			g.replaces[r.Old.Path] = r.New.Path
+			if r.New.Version == "" {
+				g.localReplaces[r.Old.Path] = struct{}{}
+			}
+		}
+	}
+	return g, nil
+}
+
+// directRequirements returns the modules required by the current
+// module's go.mod file that are not marked as indirect.
+func directRequirements() (map[string]struct{}, error) {
+	f, err := parseGoMod()
+	if err != nil {
+		return nil, err
+	}
+	direct := make(map[string]struct{})
+	for _, r := range f.Require {
+		if !r.Indirect {
+			direct[r.Mod.Path] = struct{}{}
+		}
+	}
+	return direct, nil
+}
+
+// requireComments returns the trailing comment, without its leading
+// slashes, of each require line in the current module's go.mod file
+// that is not marked as indirect.
+func requireComments() (map[string]string, error) {
+	f, err := parseGoMod()
+	if err != nil {
+		return nil, err
+	}
+	comments := make(map[string]string)
+	for _, r := range f.Require {
+		if r.Indirect || r.Syntax == nil {
+			continue
+		}
+		var text []string
+		for _, c := range r.Syntax.Suffix {
+			if s := strings.TrimSpace(strings.TrimPrefix(c.Token, "//")); s != "" {
+				text = append(text, s)
+			}
+		}
+		if len(text) > 0 {
+			comments[r.Mod.Path] = strings.Join(text, " ")
+		}
+	}
+	return comments, nil
+}
+
+// parseGoMod parses the current module's go.mod file.
+func parseGoMod() (*modfile.File, error) {
+	file, err := goModFile()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return modfile.Parse(file, data, nil)
+}
+
+// mainUsage returns, for each module other than the main module, the
+// number of non-test packages in the main module that import it.
+func mainUsage(pkgs []*packages.Package) map[string]int {
+	importers := make(map[string]map[string]bool)
+	traverse(pkgs, func(p *packages.Package) {
+		if p.Module == nil || !p.Module.Main || isTestVariant(p) {
+			return
+		}
+		for _, imp := range p.Imports {
+			if imp.Module == nil || imp.Module.Main {
+				continue
+			}
+			if importers[imp.Module.Path] == nil {
+				importers[imp.Module.Path] = make(map[string]bool)
+			}
+			importers[imp.Module.Path][p.PkgPath] = true
+		}
+	})
+	usage := make(map[string]int)
+	for m, ps := range importers {
+		usage[m] = len(ps)
+	}
+	return usage
+}
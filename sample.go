@@ -0,0 +1,60 @@
+package main
+
+import (
+	"container/list"
+	"hash/fnv"
+	"math"
+)
+
+// sampleEdges randomly removes edges from g, keeping each with
+// probability p. The edges of a breadth-first spanning tree from the
+// main module are always kept so that every node that was reachable
+// remains so. Whether an edge is kept is decided by a hash of its
+// endpoints, so the same graph is always sampled the same way.
+func (g *graph) sampleEdges(p float64) {
+	tree := make(map[edge]bool)
+	seen := make(map[string]bool)
+	q := list.New()
+	visit := func(n string) {
+		if !seen[n] {
+			seen[n] = true
+			q.PushBack(n)
+		}
+	}
+	walk := func() {
+		for q.Len() > 0 {
+			n := q.Remove(q.Front()).(string)
+			for _, to := range g.successors(n) {
+				if !seen[to] {
+					tree[edge{n, to}] = true
+					visit(to)
+				}
+			}
+		}
+	}
+	// Start with the main module, then pick up anything
+	// unreachable from it in a deterministic order.
+	visit(g.mainMod)
+	walk()
+	for _, n := range sortedKeys(g.nodes) {
+		visit(n)
+		walk()
+	}
+	for from, tos := range g.edges {
+		for to := range tos {
+			if !tree[edge{from, to}] && !keepSample(from, to, p) {
+				delete(tos, to)
+			}
+		}
+	}
+}
+
+// keepSample reports whether the edge from -> to
+// should be kept when sampling with probability p.
+func keepSample(from, to string, p float64) bool {
+	h := fnv.New64a()
+	h.Write([]byte(from))
+	h.Write([]byte{0})
+	h.Write([]byte(to))
+	return float64(h.Sum64()) < p*math.MaxUint64
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+)
+
+// prodGraph returns a copy of g holding only the edges found without
+// loading tests, and without the test-only modules.
+func (g *graph) prodGraph() *graph {
+	prod := *g
+	prod.nodes = maps.Clone(g.nodes)
+	prod.edges = make(map[string]map[string]struct{})
+	for from, tos := range g.prodEdges {
+		prod.edges[from] = maps.Clone(tos)
+	}
+	prod.keepNodes(difference(prod.nodes, difference(g.testOnly, map[string]struct{}{g.mainMod: {}})))
+	return &prod
+}
+
+// writeSideBySide writes two mermaid diagrams in markdown, the first
+// of the graph found without loading tests and the second of the
+// full graph, so that what the tests add can be seen directly.
+func writeSideBySide(out io.Writer, g *graph) {
+	prod := g.prodGraph()
+	fmt.Fprintf(out, "### Without tests (%d modules)\n\n", len(prod.nodes))
+	writeDot(out, prod)
+	fmt.Fprintf(out, "\n### With tests (%d modules)\n\n", len(g.nodes))
+	writeDot(out, g)
+}
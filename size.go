@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"path/filepath"
+)
+
+// moduleSize returns a human-readable form of the total size of the
+// files under dir, not counting any vendor directory, or "(size unknown)"
+// if dir is empty because the module's source is not available.
+func moduleSize(dir string) string {
+	if dir == "" {
+		return "(size unknown)"
+	}
+	size, err := dirSize(dir)
+	if err != nil {
+		return "(size unknown)"
+	}
+	return formatSize(size)
+}
+
+// dirSize returns the total size of the files under
+// dir, not counting any vendor directory.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == "vendor" && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	return size, err
+}
+
+// Range of edge widths used by -weight-by-size, in pixels.
+const (
+	minSizeWidth = 1
+	maxSizeWidth = 8
+)
+
+// weightBySize styles each edge of g that is not otherwise styled with
+// a stroke width that grows with the on-disk size of the module it
+// leads to. Sizes vary over orders of magnitude, so widths are spread
+// by the logarithm of the size between the smallest and the largest
+// module other than the main module, which edges rarely lead to.
+// Modules whose source is not available get the minimum width.
+func (g *graph) weightBySize() {
+	sizes := make(map[string]float64)
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for n := range g.nodes {
+		if g.dirs[n] == "" || n == g.mainMod {
+			continue
+		}
+		size, err := dirSize(g.dirs[n])
+		if err != nil {
+			continue
+		}
+		s := math.Log1p(float64(size))
+		sizes[n] = s
+		lo, hi = min(lo, s), max(hi, s)
+	}
+	for from, tos := range g.edges {
+		for to := range tos {
+			e := edge{from, to}
+			if g.edgeStyles[e] != "" {
+				continue
+			}
+			w := minSizeWidth
+			if s, ok := sizes[to]; ok && hi > lo {
+				w += int(math.Round((s - lo) / (hi - lo) * (maxSizeWidth - minSizeWidth)))
+			}
+			g.edgeStyles[e] = fmt.Sprintf("stroke-width:%dpx", w)
+		}
+	}
+}
+
+// formatSize returns n bytes in units of B, KB, MB or GB.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	f := float64(n)
+	for _, suffix := range []string{"KB", "MB", "GB"} {
+		f /= unit
+		if f < unit || suffix == "GB" {
+			return fmt.Sprintf("%.1f%s", f, suffix)
+		}
+	}
+	panic("unreachable")
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWeightBySize(t *testing.T) {
+	root := t.TempDir()
+	g := edgeGraph(
+		[2]string{"main", "small"},
+		[2]string{"main", "big"},
+		[2]string{"small", "big"},
+		[2]string{"main", "nodir"},
+		[2]string{"big", "styled"},
+	)
+	g.mainMod = "main"
+	// The main module is the largest, but it does not set the range.
+	g.dirs = make(map[string]string)
+	for n, size := range map[string]int{"main": 1e6, "small": 10, "big": 100000, "styled": 20} {
+		g.dirs[n] = filepath.Join(root, n)
+		writeFile(t, filepath.Join(g.dirs[n], "f"), strings.Repeat("x", size))
+	}
+	// The vendor directory does not count.
+	writeFile(t, filepath.Join(g.dirs["small"], "vendor", "f"), strings.Repeat("x", 1e6))
+	g.edgeStyles = map[edge]string{{"big", "styled"}: "stroke:red"}
+	g.weightBySize()
+	want := map[edge]string{
+		{"main", "small"}: "stroke-width:1px",
+		{"main", "big"}:   "stroke-width:8px",
+		{"small", "big"}:  "stroke-width:8px",
+		{"main", "nodir"}: "stroke-width:1px",
+		{"big", "styled"}: "stroke:red",
+	}
+	if !reflect.DeepEqual(g.edgeStyles, want) {
+		t.Errorf("got edge styles %v, want %v", g.edgeStyles, want)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for _, test := range []struct {
+		n    int64
+		want string
+	}{
+		{0, "0B"},
+		{1023, "1023B"},
+		{1536, "1.5KB"},
+		{5 << 20, "5.0MB"},
+		{3 << 40, "3072.0GB"},
+	} {
+		if got := formatSize(test.n); got != test.want {
+			t.Errorf("formatSize(%d) = %q, want %q", test.n, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// statsHeader holds the columns written by appendStats.
+var statsHeader = []string{"timestamp", "main", "modules", "edges", "test_only", "direct", "max_depth"}
+
+// appendStats appends a row of summary figures about g to the named
+// CSV file, creating it with a header row if it does not exist, so that
+// repeated runs build up a record of how the dependencies change.
+func appendStats(file string, g *graph) error {
+	_, err := os.Stat(file)
+	exists := err == nil
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	edges, testOnly, maxDepth := 0, 0, 0
+	for _, tos := range g.edges {
+		edges += len(tos)
+	}
+	for n := range g.testOnly {
+		if _, ok := g.nodes[n]; ok && n != g.mainMod {
+			testOnly++
+		}
+	}
+	if _, ok := g.nodes[g.mainMod]; ok {
+		for _, d := range g.depths(g.mainMod) {
+			maxDepth = max(maxDepth, d)
+		}
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o666)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	if !exists {
+		w.Write(statsHeader)
+	}
+	w.Write([]string{
+		time.Now().UTC().Format(time.RFC3339),
+		g.mainMod,
+		fmt.Sprint(len(g.nodes)),
+		fmt.Sprint(edges),
+		fmt.Sprint(testOnly),
+		fmt.Sprint(len(g.edges[g.mainMod])),
+		fmt.Sprint(maxDepth),
+	})
+	w.Flush()
+	err = w.Error()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStatsCSV(t *testing.T) {
+	dir := fixture(t, "fx/main")
+	file := filepath.Join(t.TempDir(), "stats.csv")
+	for range 2 {
+		mustRun(t, dir, "-stats-csv", file)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want a header and two rows: %q", len(records), records)
+	}
+	if !reflect.DeepEqual(records[0], statsHeader) {
+		t.Errorf("got header %q, want %q", records[0], statsHeader)
+	}
+	want := []string{"example.com/main", "10", "10", "3", "3", "3"}
+	for _, row := range records[1:] {
+		if _, err := time.Parse(time.RFC3339, row[0]); err != nil {
+			t.Errorf("bad timestamp: %v", err)
+		}
+		if !reflect.DeepEqual(row[1:], want) {
+			t.Errorf("got row %q, want %q after the timestamp", row[1:], want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"sort"
+)
+
+// Dimensions used by the SVG layout, in pixels.
+const (
+	svgCharWidth  = 7
+	svgNodeHeight = 24
+	svgNodePad    = 8
+	svgRowGap     = 16
+	svgLayerGap   = 60
+	svgMargin     = 10
+)
+
+// writeSVG writes g as an SVG image laid out without any external
+// tools. Modules are arranged left to right in layers, each module
+// placed one layer beyond its furthest predecessor, and edges are
+// drawn as straight lines. The layout is simple rather than pretty.
+func writeSVG(out io.Writer, g *graph) {
+	pos, width, totalWidth, totalHeight := g.layout()
+	fmt.Fprintf(out, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"monospace\" font-size=\"12\">\n", totalWidth, totalHeight)
+	fmt.Fprintf(out, "<defs><marker id=\"arrow\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" markerWidth=\"6\" markerHeight=\"6\" orient=\"auto\"><path d=\"M0,0 L10,5 L0,10 z\" fill=\"#333\"/></marker></defs>\n")
+	for _, from := range sortedKeys(g.edges) {
+		for _, to := range g.successors(from) {
+			p, q := pos[from], pos[to]
+			fmt.Fprintf(out, "<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"#333\" marker-end=\"url(#arrow)\"/>\n",
+				p.x+width[from], p.y+svgNodeHeight/2, q.x, q.y+svgNodeHeight/2)
+		}
+	}
+	for _, n := range sortedKeys(g.nodes) {
+		p := pos[n]
+		_, color, _ := g.classify(n)
+		fmt.Fprintf(out, "<g><title>%s</title>", html.EscapeString(n))
+		fmt.Fprintf(out, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" rx=\"4\" fill=\"%s\" stroke=\"#333\"/>",
+			p.x, p.y, width[n], svgNodeHeight, html.EscapeString(color))
+		fmt.Fprintf(out, "<text x=\"%d\" y=\"%d\" dominant-baseline=\"middle\">%s</text></g>\n",
+			p.x+svgNodePad, p.y+svgNodeHeight/2, html.EscapeString(g.label(n)))
+	}
+	fmt.Fprintf(out, "</svg>\n")
+}
+
+// point is a position in a layout.
+type point struct{ x, y int }
+
+// layout places the nodes of g in layers from left to right, as
+// described for writeSVG, and returns the top left corner and width
+// of each node along with the total width and height of the layout.
+// Every node is svgNodeHeight high.
+func (g *graph) layout() (pos map[string]point, width map[string]int, totalWidth, totalHeight int) {
+	layers := g.layers()
+
+	width = make(map[string]int)
+	layerWidth := make([]int, len(layers))
+	for i, layer := range layers {
+		for _, n := range layer {
+			width[n] = len([]rune(g.label(n)))*svgCharWidth + 2*svgNodePad
+			layerWidth[i] = max(layerWidth[i], width[n])
+		}
+	}
+	pos = make(map[string]point)
+	x, height := svgMargin, 0
+	for i, layer := range layers {
+		for j, n := range layer {
+			pos[n] = point{x, svgMargin + j*(svgNodeHeight+svgRowGap)}
+		}
+		height = max(height, len(layer)*(svgNodeHeight+svgRowGap))
+		x += layerWidth[i] + svgLayerGap
+	}
+	totalWidth = x - svgLayerGap + svgMargin
+	totalHeight = height - svgRowGap + 2*svgMargin
+	return pos, width, totalWidth, totalHeight
+}
+
+// layers assigns each node in g to a layer by the length of the longest
+// path leading to it, and returns the nodes in each layer. Within a layer,
+// nodes are ordered by the average position of their predecessors to
+// reduce edge crossings. Any edges that form cycles are ignored for
+// the purposes of layering.
+func (g *graph) layers() [][]string {
+	back := g.backEdges()
+	if len(back) > 0 {
+		log.Printf("note: dependency graph has cycles; ignoring %d edges when laying out the SVG", len(back))
+	}
+	preds := make(map[string][]string)
+	inDegree := make(map[string]int)
+	for from, tos := range g.edges {
+		for to := range tos {
+			if !back[edge{from, to}] {
+				preds[to] = append(preds[to], from)
+				inDegree[to]++
+			}
+		}
+	}
+	// Visit nodes in topological order so that every
+	// predecessor is placed before its successors.
+	layer := make(map[string]int)
+	var ready []string
+	for _, n := range sortedKeys(g.nodes) {
+		if inDegree[n] == 0 {
+			ready = append(ready, n)
+		}
+	}
+	nlayers := 0
+	for len(ready) > 0 {
+		n := ready[0]
+		ready = ready[1:]
+		for _, p := range preds[n] {
+			layer[n] = max(layer[n], layer[p]+1)
+		}
+		nlayers = max(nlayers, layer[n]+1)
+		for _, to := range g.successors(n) {
+			if back[edge{n, to}] {
+				continue
+			}
+			if inDegree[to]--; inDegree[to] == 0 {
+				ready = append(ready, to)
+			}
+		}
+	}
+	layers := make([][]string, nlayers)
+	for _, n := range sortedKeys(g.nodes) {
+		layers[layer[n]] = append(layers[layer[n]], n)
+	}
+	index := make(map[string]int)
+	for i, nodes := range layers {
+		if i > 0 {
+			centre := func(n string) float64 {
+				sum := 0
+				for _, p := range preds[n] {
+					sum += index[p]
+				}
+				return float64(sum) / float64(max(len(preds[n]), 1))
+			}
+			sort.SliceStable(nodes, func(i, j int) bool {
+				return centre(nodes[i]) < centre(nodes[j])
+			})
+		}
+		for j, n := range nodes {
+			index[n] = j
+		}
+	}
+	return layers
+}
+
+// backEdges returns the edges of g that close a cycle
+// when the graph is searched depth-first in name order.
+func (g *graph) backEdges() map[edge]bool {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	back := make(map[edge]bool)
+	var visit func(n string)
+	visit = func(n string) {
+		state[n] = visiting
+		for _, to := range sortedKeys(g.edges[n]) {
+			switch state[to] {
+			case visiting:
+				back[edge{n, to}] = true
+			case 0:
+				visit(to)
+			}
+		}
+		state[n] = done
+	}
+	for _, n := range sortedKeys(g.nodes) {
+		if state[n] == 0 {
+			visit(n)
+		}
+	}
+	return back
+}
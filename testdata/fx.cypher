@@ -0,0 +1,20 @@
+MERGE (n:Module {path: 'example.com/a'}) SET n.version = 'v1.0.0', n.main = false, n.testOnly = false;
+MERGE (n:Module {path: 'example.com/b'}) SET n.version = 'v1.0.0', n.main = false, n.testOnly = false;
+MERGE (n:Module {path: 'example.com/c'}) SET n.version = 'v1.0.0', n.main = false, n.testOnly = false;
+MERGE (n:Module {path: 'example.com/d'}) SET n.version = 'v1.0.0', n.main = false, n.testOnly = false;
+MERGE (n:Module {path: 'example.com/e'}) SET n.version = 'v1.0.0', n.main = false, n.testOnly = false;
+MERGE (n:Module {path: 'example.com/f'}) SET n.version = 'v1.0.0', n.main = false, n.testOnly = true;
+MERGE (n:Module {path: 'example.com/g'}) SET n.version = 'v1.0.0', n.main = false, n.testOnly = true;
+MERGE (n:Module {path: 'example.com/main'}) SET n.version = '', n.main = true, n.testOnly = false;
+MERGE (n:Module {path: 'example.com/t'}) SET n.version = 'v1.0.0', n.main = false, n.testOnly = false;
+MERGE (n:Module {path: 'example.com/x'}) SET n.version = 'v1.0.0', n.main = false, n.testOnly = true;
+MATCH (a:Module {path: 'example.com/a'}), (b:Module {path: 'example.com/c'}) MERGE (a)-[r:DEPENDS_ON]->(b) SET r.test = false;
+MATCH (a:Module {path: 'example.com/a'}), (b:Module {path: 'example.com/f'}) MERGE (a)-[r:DEPENDS_ON]->(b) SET r.test = true;
+MATCH (a:Module {path: 'example.com/b'}), (b:Module {path: 'example.com/c'}) MERGE (a)-[r:DEPENDS_ON]->(b) SET r.test = false;
+MATCH (a:Module {path: 'example.com/b'}), (b:Module {path: 'example.com/d'}) MERGE (a)-[r:DEPENDS_ON]->(b) SET r.test = false;
+MATCH (a:Module {path: 'example.com/c'}), (b:Module {path: 'example.com/x'}) MERGE (a)-[r:DEPENDS_ON]->(b) SET r.test = true;
+MATCH (a:Module {path: 'example.com/f'}), (b:Module {path: 'example.com/g'}) MERGE (a)-[r:DEPENDS_ON]->(b) SET r.test = true;
+MATCH (a:Module {path: 'example.com/main'}), (b:Module {path: 'example.com/a'}) MERGE (a)-[r:DEPENDS_ON]->(b) SET r.test = false;
+MATCH (a:Module {path: 'example.com/main'}), (b:Module {path: 'example.com/b'}) MERGE (a)-[r:DEPENDS_ON]->(b) SET r.test = false;
+MATCH (a:Module {path: 'example.com/main'}), (b:Module {path: 'example.com/t'}) MERGE (a)-[r:DEPENDS_ON]->(b) SET r.test = true;
+MATCH (a:Module {path: 'example.com/t'}), (b:Module {path: 'example.com/e'}) MERGE (a)-[r:DEPENDS_ON]->(b) SET r.test = false;
@@ -0,0 +1,7 @@
+package a
+
+import "example.com/c"
+
+func A() {
+	c.C()
+}
@@ -0,0 +1,9 @@
+package a
+
+import (
+	"testing"
+
+	"example.com/f"
+)
+
+func TestA(t *testing.T) { f.F() }
@@ -0,0 +1,15 @@
+module example.com/a
+
+go 1.22
+
+require example.com/c v1.0.0
+
+replace example.com/c => ../c
+
+require example.com/f v1.0.0
+
+require example.com/g v1.0.0 // indirect
+
+replace example.com/f => ../f
+
+replace example.com/g => ../g
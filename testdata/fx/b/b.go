@@ -0,0 +1,9 @@
+package b
+
+import "example.com/c"
+import "example.com/d"
+
+func B() {
+	c.C()
+	d.D()
+}
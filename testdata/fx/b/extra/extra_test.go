@@ -0,0 +1,9 @@
+package extra
+
+import (
+	"testing"
+
+	"example.com/h"
+)
+
+func TestX(t *testing.T) { h.H() }
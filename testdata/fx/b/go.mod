@@ -0,0 +1,10 @@
+module example.com/b
+
+go 1.22
+
+require example.com/c v1.0.0
+replace example.com/c => ../c
+require example.com/d v1.0.0
+replace example.com/d => ../d
+require example.com/h v1.0.0
+replace example.com/h => ../h
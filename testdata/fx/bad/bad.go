@@ -0,0 +1,2 @@
+// Package bad has files that disagree about its name, for testing -broken.
+package bad
@@ -0,0 +1,8 @@
+package c_test
+
+import "example.com/x"
+
+func ExampleC() {
+	x.X()
+	// Output:
+}
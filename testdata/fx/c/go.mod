@@ -0,0 +1,6 @@
+module example.com/c
+
+go 1.22
+
+require example.com/x v1.0.0
+replace example.com/x => ../x
@@ -0,0 +1,4 @@
+package d
+
+func D() {
+}
@@ -0,0 +1,4 @@
+module example.com/d
+
+go 1.22
+
@@ -0,0 +1,4 @@
+package e
+
+func E() {
+}
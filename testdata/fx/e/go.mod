@@ -0,0 +1,4 @@
+module example.com/e
+
+go 1.22
+
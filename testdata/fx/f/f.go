@@ -0,0 +1,7 @@
+package f
+
+import "example.com/g"
+
+func F() {
+	g.G()
+}
@@ -0,0 +1,6 @@
+module example.com/f
+
+go 1.22
+
+require example.com/g v1.0.0
+replace example.com/g => ../g
@@ -0,0 +1,4 @@
+package g
+
+func G() {
+}
@@ -0,0 +1,4 @@
+module example.com/g
+
+go 1.22
+
@@ -0,0 +1,33 @@
+module example.com/main
+
+go 1.22
+
+require (
+	example.com/a v1.0.0
+	example.com/b v1.0.0
+	example.com/t v1.0.0
+)
+
+require (
+	example.com/c v1.0.0 // indirect
+	example.com/d v1.0.0 // indirect
+	example.com/e v1.0.0 // indirect
+	example.com/g v1.0.0 // indirect
+)
+
+replace (
+	example.com/a => ../a
+	example.com/b => ../b
+	example.com/c => ../c
+	example.com/d => ../d
+	example.com/e => ../e
+	example.com/t => ../t
+)
+
+replace example.com/f => ../f
+
+replace example.com/g => ../g
+
+replace example.com/h => ../h
+
+replace example.com/x => ../x
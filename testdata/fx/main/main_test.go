@@ -0,0 +1,9 @@
+package main
+
+import (
+	"testing"
+
+	"example.com/t"
+)
+
+func TestX(t_ *testing.T) { t.T() }
@@ -0,0 +1,3 @@
+package main2
+
+import _ "example.com/b"
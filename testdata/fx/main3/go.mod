@@ -0,0 +1,21 @@
+module example.com/main3
+
+go 1.22
+
+require (
+	example.com/b v1.0.0
+	example.com/bad v1.0.0
+)
+
+require (
+	example.com/c v1.0.0 // indirect
+	example.com/d v1.0.0 // indirect
+)
+
+replace (
+	example.com/b => ../b
+	example.com/bad => ../bad
+	example.com/c => ../c
+	example.com/d => ../d
+	example.com/x => ../x
+)
@@ -0,0 +1,6 @@
+package main3
+
+import (
+	_ "example.com/b"
+	_ "example.com/bad"
+)
@@ -0,0 +1,6 @@
+module example.com/t
+
+go 1.22
+
+require example.com/e v1.0.0
+replace example.com/e => ../e
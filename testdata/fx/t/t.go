@@ -0,0 +1,7 @@
+package t
+
+import "example.com/e"
+
+func T() {
+	e.E()
+}
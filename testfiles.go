@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// testFileAttribution returns, for each test file of the packages in
+// pkgs, the test-only nodes of g that the packages it imports reach.
+// Files that lead to no test-only node are omitted.
+func testFileAttribution(g *graph, pkgs []*packages.Package) map[string]map[string]struct{} {
+	fset := token.NewFileSet()
+	byFile := make(map[string]map[string]struct{})
+	traverse(pkgs, func(p *packages.Package) {
+		if !isTestVariant(p) {
+			return
+		}
+		for _, file := range testFiles(p) {
+			if _, ok := byFile[file]; ok {
+				continue
+			}
+			f, err := parser.ParseFile(fset, file, nil, parser.ImportsOnly)
+			if err != nil {
+				log.Printf("cannot parse %s: %v", file, err)
+				continue
+			}
+			var roots []*packages.Package
+			for _, spec := range f.Imports {
+				path, err := strconv.Unquote(spec.Path.Value)
+				if err != nil {
+					continue
+				}
+				if imp := p.Imports[path]; imp != nil {
+					roots = append(roots, imp)
+				}
+			}
+			reached := make(map[string]struct{})
+			traverse(roots, func(q *packages.Package) {
+				if n := nodeOf(q); n != "" {
+					if _, ok := g.testOnly[n]; ok {
+						reached[n] = struct{}{}
+					}
+				}
+			})
+			byFile[file] = reached
+		}
+	})
+	for file, reached := range byFile {
+		if len(reached) == 0 {
+			delete(byFile, file)
+		}
+	}
+	return byFile
+}
+
+// writeTestFileReport writes a line for each test file in pkgs and each
+// test-only module of g that the file's imports lead to. If without is
+// not nil, it is the graph loaded as if some test files were absent,
+// and the report goes on to show which test-only modules of g it
+// keeps and which it removes.
+func writeTestFileReport(out io.Writer, g *graph, pkgs []*packages.Package, without *graph) {
+	byFile := testFileAttribution(g, pkgs)
+	wd, _ := os.Getwd()
+	for _, file := range sortedKeys(byFile) {
+		name := file
+		if rel, err := filepath.Rel(wd, file); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+		for _, m := range sortedKeys(byFile[file]) {
+			fmt.Fprintf(out, "%s\t%s\n", name, m)
+		}
+	}
+	if without == nil {
+		return
+	}
+	before := intersection(g.testOnly, g.nodes)
+	after := intersection(without.testOnly, without.nodes)
+	fmt.Fprintf(out, "\ntest-only modules: %d before, %d after suppressing\n", len(before), len(after))
+	for _, m := range sortedKeys(before) {
+		if _, ok := after[m]; ok {
+			fmt.Fprintf(out, "\tkept\t%s\n", m)
+		} else {
+			fmt.Fprintf(out, "\tremoved\t%s\n", m)
+		}
+	}
+}
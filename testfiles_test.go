@@ -0,0 +1,33 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTestFileReport(t *testing.T) {
+	fx := fixture(t, "fx")
+	dir := filepath.Join(fx, "main")
+	aTest := filepath.Join(fx, "a", "a_test.go")
+	cTest := filepath.Join(fx, "c", "example_test.go")
+	files := "" +
+		aTest + "\texample.com/f\n" +
+		aTest + "\texample.com/g\n" +
+		cTest + "\texample.com/x\n"
+	if got := mustRun(t, dir, "-filter-test-edges-by-file"); got != files {
+		t.Errorf("got report:\n%s\nwant:\n%s", got, files)
+	}
+	want := files + "\n" +
+		"test-only modules: 3 before, 1 after suppressing\n" +
+		"\tremoved\texample.com/f\n" +
+		"\tremoved\texample.com/g\n" +
+		"\tkept\texample.com/x\n"
+	if got := mustRun(t, dir, "-filter-test-edges-by-file", "-suppress-file", `^a_test\.go$`); got != want {
+		t.Errorf("with -suppress-file, got report:\n%s\nwant:\n%s", got, want)
+	}
+	r := runMain(t, dir, nil, "-suppress-file", "x")
+	if !r.failed || !strings.Contains(r.stderr, "-suppress-file requires -filter-test-edges-by-file") {
+		t.Errorf("-suppress-file alone did not fail as expected:\n%s", r.stderr)
+	}
+}
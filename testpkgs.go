@@ -0,0 +1,30 @@
+package main
+
+import "golang.org/x/tools/go/packages"
+
+// testOnlyPackageStyle is the mermaid style used for
+// packages that are compiled only when tests are.
+const testOnlyPackageStyle = "stroke:#c00,stroke-width:3px,stroke-dasharray:6 3"
+
+// testOnlyPackages returns the nodes for the packages, identified by
+// PkgPath, that appear in testPkgs but not in noTestPkgs. Unlike the
+// test-only modules, this is a plain difference of the two loads and
+// so picks out test helper packages within modules that are otherwise
+// shared with production code. A node that any package in noTestPkgs
+// also maps to is not included, so external test packages and test
+// variants do not mark the package they test.
+func testOnlyPackages(noTestPkgs, testPkgs []*packages.Package) map[string]struct{} {
+	prodPaths := make(map[string]bool)
+	prodNodes := make(map[string]bool)
+	traverse(noTestPkgs, func(p *packages.Package) {
+		prodPaths[p.PkgPath] = true
+		prodNodes[nodeOf(p)] = true
+	})
+	only := make(map[string]struct{})
+	traverse(testPkgs, func(p *packages.Package) {
+		if n := nodeOf(p); n != "" && !prodPaths[p.PkgPath] && !prodNodes[n] {
+			only[n] = struct{}{}
+		}
+	})
+	return only
+}
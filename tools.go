@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// toolStyle is the mermaid style used for modules
+// that provide tools listed in the main go.mod file.
+const toolStyle = "stroke:#696,stroke-width:2px,stroke-dasharray:6 3"
+
+// labeledEdge is an edge drawn in addition
+// to the import edges, with a label.
+type labeledEdge struct {
+	edge
+	label string
+}
+
+// toolModules returns the modules providing the tools listed in the tool
+// directives of the named go.mod file. A tool belongs to the required
+// module with the longest path that is a prefix of the tool's package path.
+// Tools provided by the main module itself are omitted.
+func toolModules(goMod string) (map[string]struct{}, error) {
+	data, err := os.ReadFile(goMod)
+	if err != nil {
+		return nil, err
+	}
+	f, err := modfile.Parse(goMod, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	mods := make(map[string]struct{})
+	for _, t := range f.Tool {
+		best := ""
+		for _, r := range f.Require {
+			if p := r.Mod.Path; len(p) > len(best) && (t.Path == p || strings.HasPrefix(t.Path, p+"/")) {
+				best = p
+			}
+		}
+		if best == "" {
+			if f.Module == nil || !strings.HasPrefix(t.Path+"/", f.Module.Mod.Path+"/") {
+				return nil, fmt.Errorf("cannot find module providing tool %s", t.Path)
+			}
+			continue
+		}
+		mods[best] = struct{}{}
+	}
+	return mods, nil
+}
+
+// addToolEdges adds a labeled edge from the main module to each of
+// the given tool modules, adding nodes for any that are not already
+// in the graph, and highlights the tool modules. An existing import
+// edge to a tool module is kept alongside the tool edge.
+func (g *graph) addToolEdges(tools map[string]struct{}) {
+	for _, m := range sortedKeys(tools) {
+		g.nodes[m] = struct{}{}
+		g.extraEdges = append(g.extraEdges, labeledEdge{edge{g.mainMod, m}, "tool"})
+	}
+	g.overlays = append(g.overlays, classOverlay{
+		name:  "toolDep",
+		style: toolStyle,
+		nodes: tools,
+	})
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// treeGlyphs holds the strings used to draw the branches of a tree.
+type treeGlyphs struct {
+	branch, last, pipe, space string
+}
+
+var (
+	unicodeGlyphs = treeGlyphs{"├── ", "└── ", "│   ", "    "}
+	asciiGlyphs   = treeGlyphs{"|-- ", "`-- ", "|   ", "    "}
+)
+
+// writeTree writes g as an indented tree rooted at the main module.
+// Each module is expanded only the first time it is encountered;
+// later occurrences of modules with dependencies are marked with (*),
+// which also stops cycles from being expanded forever. Any modules not reachable from the
+// main module are shown as separate trees afterwards.
+func writeTree(out io.Writer, g *graph) {
+	glyphs := unicodeGlyphs
+	if *flagASCII {
+		glyphs = asciiGlyphs
+	}
+	expanded := make(map[string]bool)
+	var walk func(name, prefix string)
+	walk = func(name, prefix string) {
+		tos := g.successors(name)
+		for i, to := range tos {
+			branch, indent := glyphs.branch, glyphs.pipe
+			if i == len(tos)-1 {
+				branch, indent = glyphs.last, glyphs.space
+			}
+			fmt.Fprintf(out, "%s%s%s", prefix, branch, g.treeLabel(to))
+			if expanded[to] {
+				if len(g.edges[to]) > 0 {
+					fmt.Fprintf(out, " (*)")
+				}
+				fmt.Fprintf(out, "\n")
+				continue
+			}
+			fmt.Fprintf(out, "\n")
+			expanded[to] = true
+			walk(to, prefix+indent)
+		}
+	}
+	roots := []string{g.mainMod}
+	if _, ok := g.nodes[g.mainMod]; !ok {
+		roots = nil
+	}
+	// Prefer to start the remaining trees from modules that nothing
+	// depends on, so that as much structure as possible is shown.
+	hasPred := make(map[string]bool)
+	for _, tos := range g.edges {
+		for to := range tos {
+			hasPred[to] = true
+		}
+	}
+	for _, n := range sortedKeys(g.nodes) {
+		if n != g.mainMod && !hasPred[n] {
+			roots = append(roots, n)
+		}
+	}
+	for _, n := range sortedKeys(g.nodes) {
+		if n != g.mainMod && hasPred[n] {
+			roots = append(roots, n)
+		}
+	}
+	for _, root := range roots {
+		if expanded[root] {
+			continue
+		}
+		expanded[root] = true
+		fmt.Fprintf(out, "%s\n", g.treeLabel(root))
+		walk(root, "")
+	}
+}
+
+// treeLabel returns the label for a node in the tree format.
+func (g *graph) treeLabel(name string) string {
+	label := g.label(name)
+	if _, ok := g.testOnly[name]; ok && name != g.mainMod {
+		label += " [test]"
+	}
+	return label
+}
@@ -0,0 +1,21 @@
+package main
+
+import "runtime/debug"
+
+// version may be set at build time with
+//
+//	go build -ldflags "-X main.version=v1.2.3"
+var version string
+
+// toolVersion returns the version of this program: the value of
+// version if set, or the module version recorded by go install,
+// or "devel" if neither is available.
+func toolVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "devel"
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// vulnStyle is the style used to highlight modules
+// with known vulnerabilities affecting their selected version.
+const vulnStyle = "fill:#ff4d4d,color:#ffffff,stroke:#800,stroke-width:2px"
+
+// osvBatchURL is the endpoint of the OSV batch query API.
+const osvBatchURL = "https://api.osv.dev/v1/querybatch"
+
+// vulnerabilities returns the IDs of the known vulnerabilities
+// affecting the selected version of each module in g that has any.
+// It uses govulncheck when it is installed, and otherwise queries the
+// OSV database directly. Modules whose status could not be determined
+// are absent from the result, as are the main module and modules
+// without a version, such as those replaced by local directories.
+func (g *graph) vulnerabilities() (map[string][]string, error) {
+	if _, err := exec.LookPath("govulncheck"); err == nil {
+		return govulncheck()
+	}
+	var mods []string
+	for _, n := range sortedKeys(g.nodes) {
+		if n != g.mainMod && g.versions[n] != "" {
+			mods = append(mods, n)
+		}
+	}
+	return queryOSV(http.DefaultClient, osvBatchURL, mods, g.versions)
+}
+
+// govulncheck runs govulncheck over the modules required by the current
+// module and returns the IDs of the vulnerabilities found in each one.
+func govulncheck() (map[string][]string, error) {
+	cmd := exec.Command("govulncheck", "-json", "-scan", "module")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("govulncheck: %v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return parseGovulncheck(out)
+}
+
+// parseGovulncheck returns the IDs of the vulnerabilities reported for
+// each module in the given stream of govulncheck JSON messages. Each
+// finding is attributed to the module at the start of its trace.
+func parseGovulncheck(data []byte) (map[string][]string, error) {
+	var msg struct {
+		Finding *struct {
+			OSV   string `json:"osv"`
+			Trace []struct {
+				Module string `json:"module"`
+			} `json:"trace"`
+		} `json:"finding"`
+	}
+	seen := make(map[string]map[string]bool)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for dec.More() {
+		msg.Finding = nil
+		if err := dec.Decode(&msg); err != nil {
+			return nil, fmt.Errorf("cannot parse govulncheck output: %v", err)
+		}
+		f := msg.Finding
+		if f == nil || len(f.Trace) == 0 {
+			continue
+		}
+		mod := f.Trace[0].Module
+		if seen[mod] == nil {
+			seen[mod] = make(map[string]bool)
+		}
+		seen[mod][f.OSV] = true
+	}
+	vulns := make(map[string][]string)
+	for mod, ids := range seen {
+		vulns[mod] = sortedKeys(ids)
+	}
+	return vulns, nil
+}
+
+// osvBatchSize is the largest number of queries
+// that the OSV batch API accepts in one request.
+const osvBatchSize = 1000
+
+// queryOSV asks the OSV batch API at url for the vulnerabilities
+// affecting each of the given modules at its version in versions,
+// sending as many requests as are needed to keep within osvBatchSize.
+func queryOSV(client *http.Client, url string, mods []string, versions map[string]string) (map[string][]string, error) {
+	if client.Timeout == 0 {
+		c := *client
+		c.Timeout = 30 * time.Second
+		client = &c
+	}
+	vulns := make(map[string][]string)
+	for len(mods) > 0 {
+		batch := mods[:min(len(mods), osvBatchSize)]
+		mods = mods[len(batch):]
+		if err := queryOSVBatch(client, url, batch, versions, vulns); err != nil {
+			return nil, err
+		}
+	}
+	return vulns, nil
+}
+
+// queryOSVBatch makes a single request to the OSV batch API at url
+// for the given modules, adding the vulnerabilities found to vulns.
+func queryOSVBatch(client *http.Client, url string, mods []string, versions map[string]string, vulns map[string][]string) error {
+	type query struct {
+		Package struct {
+			Name      string `json:"name"`
+			Ecosystem string `json:"ecosystem"`
+		} `json:"package"`
+		Version string `json:"version"`
+	}
+	var req struct {
+		Queries []query `json:"queries"`
+	}
+	for _, m := range mods {
+		var q query
+		q.Package.Name = m
+		q.Package.Ecosystem = "Go"
+		// OSV records Go versions without the leading v.
+		q.Version = strings.TrimPrefix(versions[m], "v")
+		req.Queries = append(req.Queries, q)
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("cannot query OSV: %v", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("OSV query was rate limited; try again later")
+	case resp.StatusCode != http.StatusOK:
+		line, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		return fmt.Errorf("OSV query failed: %s: %s", resp.Status, strings.TrimSpace(line))
+	}
+	var result struct {
+		Results []struct {
+			Vulns []struct {
+				ID string `json:"id"`
+			} `json:"vulns"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("cannot parse OSV response: %v", err)
+	}
+	if len(result.Results) != len(mods) {
+		return fmt.Errorf("OSV returned %d results for %d modules", len(result.Results), len(mods))
+	}
+	for i, r := range result.Results {
+		for _, v := range r.Vulns {
+			vulns[mods[i]] = append(vulns[mods[i]], v.ID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeOSV is a stand-in for the OSV batch API that reports a
+// vulnerability for every module at version 1.0.0 and records the
+// number of queries in each request.
+type fakeOSV struct {
+	batches []int
+}
+
+func (f *fakeOSV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Queries []struct {
+			Package struct {
+				Name      string `json:"name"`
+				Ecosystem string `json:"ecosystem"`
+			} `json:"package"`
+			Version string `json:"version"`
+		} `json:"queries"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Queries) > osvBatchSize {
+		http.Error(w, "Too many queries", http.StatusBadRequest)
+		return
+	}
+	f.batches = append(f.batches, len(req.Queries))
+	type vuln struct {
+		ID string `json:"id"`
+	}
+	type result struct {
+		Vulns []vuln `json:"vulns,omitempty"`
+	}
+	results := make([]result, len(req.Queries))
+	for i, q := range req.Queries {
+		if q.Package.Ecosystem == "Go" && q.Version == "1.0.0" {
+			results[i].Vulns = []vuln{{"GO-" + q.Package.Name}}
+		}
+	}
+	json.NewEncoder(w).Encode(map[string][]result{"results": results})
+}
+
+func TestQueryOSV(t *testing.T) {
+	osv := &fakeOSV{}
+	srv := httptest.NewServer(osv)
+	defer srv.Close()
+	var mods []string
+	versions := make(map[string]string)
+	want := make(map[string][]string)
+	for i := 0; i < 2*osvBatchSize+500; i++ {
+		m := fmt.Sprintf("example.com/m%d", i)
+		mods = append(mods, m)
+		versions[m] = "v1.1.0"
+		if i%700 == 0 {
+			versions[m] = "v1.0.0"
+			want[m] = []string{"GO-" + m}
+		}
+	}
+	got, err := queryOSV(srv.Client(), srv.URL, mods, versions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got vulnerabilities %v, want %v", got, want)
+	}
+	if want := []int{osvBatchSize, osvBatchSize, 500}; !reflect.DeepEqual(osv.batches, want) {
+		t.Errorf("got batches of %v queries, want %v", osv.batches, want)
+	}
+}
+
+func TestQueryOSVError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+	_, err := queryOSV(srv.Client(), srv.URL, []string{"example.com/a"}, map[string]string{"example.com/a": "v1.0.0"})
+	if err == nil || !strings.Contains(err.Error(), "rate limited") {
+		t.Errorf("got error %v, want a rate limit error", err)
+	}
+}
+
+func TestParseGovulncheck(t *testing.T) {
+	data := `{"config":{"scanner_name":"govulncheck"}}
+{"osv":{"id":"GO-2024-0001"}}
+{"finding":{"osv":"GO-2024-0001","trace":[{"module":"example.com/a"},{"module":"example.com/main"}]}}
+{"finding":{"osv":"GO-2024-0002","trace":[{"module":"example.com/a"}]}}
+{"finding":{"osv":"GO-2024-0001","trace":[{"module":"example.com/a"}]}}
+{"finding":{"osv":"GO-2024-0003","trace":[{"module":"example.com/b"}]}}
+{"finding":{"osv":"GO-2024-0004","trace":[]}}
+`
+	got, err := parseGovulncheck([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"example.com/a": {"GO-2024-0001", "GO-2024-0002"},
+		"example.com/b": {"GO-2024-0003"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
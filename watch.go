@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// watchInterval is how often -watch-git checks the checked-out commit.
+const watchInterval = time.Second
+
+// watchGit regenerates the output each time the commit checked out in
+// the current git repository changes, whether by switching branches,
+// committing, or moving a detached HEAD as git bisect does. Each graph is
+// made by running this program again with the same arguments other than
+// -watch-git. A change is acted on only once the commit has stayed the
+// same for a whole interval, so that a burst of changes, such as
+// those made by a rebase, leads to just one regeneration. It never
+// returns.
+func watchGit() {
+	args := watchArgs(flag.CommandLine)
+	self, err := os.Executable()
+	if err != nil {
+		log.Fatalf("cannot find this program: %v", err)
+	}
+	generate := func(commit string) {
+		log.Printf("regenerating %s for %s", *flagOutput, commit)
+		cmd := exec.Command(self, args...)
+		cmd.Env = watchEnv(os.Environ())
+		cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+		if err := cmd.Run(); err != nil {
+			log.Printf("cannot regenerate: %v", err)
+		}
+	}
+	current, err := headCommit()
+	if err != nil {
+		log.Fatalf("cannot use -watch-git: %v", err)
+	}
+	generate(current)
+	pending := ""
+	for range time.Tick(watchInterval) {
+		commit, err := headCommit()
+		if err != nil {
+			log.Printf("cannot read HEAD: %v", err)
+			continue
+		}
+		switch {
+		case commit != pending:
+			pending = commit
+		case commit != current:
+			current = commit
+			generate(current)
+		}
+	}
+}
+
+// watchArgs returns the arguments with which -watch-git runs this
+// program to make each graph: every flag set on the command line of fs
+// other than -watch-git itself, with -watch-git=false added to be sure
+// that the child does not start watching in turn, however the flag was
+// given.
+func watchArgs(fs *flag.FlagSet) []string {
+	var args []string
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "watch-git" {
+			return
+		}
+		if r, ok := f.Value.(repeatedValue); ok {
+			for _, v := range r.values() {
+				args = append(args, "-"+f.Name+"="+v)
+			}
+			return
+		}
+		args = append(args, "-"+f.Name+"="+f.Value.String())
+	})
+	return append(args, "-watch-git=false")
+}
+
+// watchEnv returns env without any setting of -watch-git,
+// for the environment of the programs run by -watch-git.
+func watchEnv(env []string) []string {
+	var kept []string
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, "GOTESTDEPS_WATCH_GIT=") {
+			kept = append(kept, kv)
+		}
+	}
+	return kept
+}
+
+// headCommit returns a description of the commit checked out in the
+// current git repository: its hash, followed by the branch name
+// unless HEAD is detached.
+func headCommit() (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "rev-parse", "HEAD")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git rev-parse: %v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	commit := strings.TrimSpace(string(out))
+	branch, err := exec.Command("git", "symbolic-ref", "-q", "--short", "HEAD").Output()
+	if err != nil {
+		return commit + " (detached)", nil
+	}
+	return commit + " (" + strings.TrimSpace(string(branch)) + ")", nil
+}